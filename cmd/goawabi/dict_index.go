@@ -0,0 +1,26 @@
+package main
+
+import (
+	"flag"
+	"github.com/nakagami/goawabi"
+)
+
+func dictIndex(args []string) {
+	fs := flag.NewFlagSet("dict-index", flag.ExitOnError)
+	var (
+		d = fs.String("d", ".", "dictionary source directory, or system dictionary directory with -u")
+		o = fs.String("o", ".", "output directory")
+		u = fs.String("u", "", "build user dictionary from csv files")
+	)
+	fs.Parse(args)
+
+	var err error
+	if *u != "" {
+		err = goawabi.CompileUserDictionary(*d, fs.Args(), *u)
+	} else {
+		err = goawabi.CompileDictionary(*d, *o)
+	}
+	if err != nil {
+		fatal(err)
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"github.com/nakagami/goawabi"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func dump(args []string) {
+	fs := flag.NewFlagSet("dump", flag.ExitOnError)
+	var (
+		d = fs.String("d", ".", "compiled dictionary directory")
+		o = fs.String("o", ".", "output directory")
+	)
+	fs.Parse(args)
+
+	// dump each file to stdout
+	if fs.NArg() > 0 {
+		for _, path := range fs.Args() {
+			var f func(string, io.Writer) error
+			switch name := filepath.Base(path); {
+			case name == "matrix.bin":
+				f = goawabi.DumpMatrix
+			case name == "char.bin":
+				f = goawabi.DumpCharProperty
+			case strings.HasSuffix(name, ".dic"):
+				f = goawabi.DumpDictionary
+			default:
+				fatal(errors.New("Unknown dictionary file: " + path))
+			}
+			if err := f(path, os.Stdout); err != nil {
+				fatal(err)
+			}
+		}
+		return
+	}
+
+	err := goawabi.DecompileDictionary(*d, *o)
+	if err != nil {
+		fatal(err)
+	}
+}
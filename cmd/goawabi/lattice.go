@@ -0,0 +1,50 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"github.com/nakagami/goawabi"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strings"
+)
+
+func lattice(args []string) {
+	fs := flag.NewFlagSet("lattice", flag.ExitOnError)
+	var (
+		format = fs.String("format", "dot", "output format, dot or html")
+	)
+	fs.Parse(args)
+	if *format != "dot" && *format != "html" {
+		fatal(errors.New("Unknown lattice format: " + *format))
+	}
+
+	tokenizer, err := goawabi.NewTokenizer("")
+	if err != nil {
+		fatal(err)
+	}
+	input, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		fatal(err)
+	}
+
+	lats := make([]*goawabi.Lattice, 0)
+	for _, s := range regexp.MustCompile("\r\n|\n\r|\n|\r").Split(strings.TrimSpace(string(input)), -1) {
+		lat, err := tokenizer.BuildLattice(s)
+		if err != nil {
+			fatal(err)
+		}
+		if *format == "dot" {
+			if err := lat.WriteDot(os.Stdout); err != nil {
+				fatal(err)
+			}
+		}
+		lats = append(lats, lat)
+	}
+	if *format == "html" {
+		if err := goawabi.WriteLatticesHTML(os.Stdout, lats); err != nil {
+			fatal(err)
+		}
+	}
+}
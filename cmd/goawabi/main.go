@@ -10,6 +10,11 @@ import (
 	"strings"
 )
 
+func fatal(err error) {
+	fmt.Fprintf(os.Stderr, "goawabi: %v\n", err)
+	os.Exit(1)
+}
+
 func print(morphemes [][2]string) {
 	for _, m := range morphemes {
 		fmt.Printf("%s\t%s\n", m[0], m[1])
@@ -17,28 +22,100 @@ func print(morphemes [][2]string) {
 	fmt.Printf("EOS\n")
 }
 
+func printMarginal(tokens []goawabi.Token) {
+	for _, t := range tokens {
+		fmt.Printf("%s\t%s\t%f\n", t.Surface, t.Feature, t.Prob)
+	}
+	fmt.Printf("EOS\n")
+}
+
+func printAll(tokens []goawabi.Token) {
+	// start, end, surface, feature, word cost, best cost, '*' if on the best path
+	for _, t := range tokens {
+		best := ""
+		if t.Best {
+			best = "*"
+		}
+		fmt.Printf("%d\t%d\t%s\t%s\t%d\t%d\t%s\n", t.Start, t.End, t.Surface, t.Feature, t.WordCost, t.Cost, best)
+	}
+	fmt.Printf("EOS\n")
+}
+
+func printFormat(tokenizer *goawabi.Tokenizer, s string, tokens []goawabi.Token, name string) {
+	out, err := tokenizer.FormatTokens(s, tokens, name)
+	if err != nil {
+		fatal(err)
+	}
+	fmt.Print(out)
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "dict-index":
+			dictIndex(os.Args[2:])
+			return
+		case "dump":
+			dump(os.Args[2:])
+			return
+		case "lattice":
+			lattice(os.Args[2:])
+			return
+		}
+	}
+
 	var (
 		n = flag.Int("N", 1, "N best")
+		o = flag.String("O", "", "output format name in dicrc (e.g. wakati, yomi)")
+		m = flag.Bool("m", false, "output marginal probabilities")
+		a = flag.Bool("a", false, "output all morphs in the lattice")
 	)
 	flag.Parse()
 
 	tokenizer, err := goawabi.NewTokenizer("")
 	if err != nil {
-		panic(err)
+		fatal(err)
 	}
 
 	input, err := ioutil.ReadAll(os.Stdin)
 	if err != nil {
-		panic(err)
+		fatal(err)
 	}
 
 	for _, s := range regexp.MustCompile("\r\n|\n\r|\n|\r").Split(strings.TrimSpace(string(input)), -1) {
 
-		if *n > 1 {
+		if *a {
+			tokens, err := tokenizer.TokenizeAll(s)
+			if err != nil {
+				fatal(err)
+			}
+			if *o != "" {
+				printFormat(tokenizer, s, tokens, *o)
+			} else {
+				printAll(tokens)
+			}
+		} else if *m {
+			tokens, _, err := tokenizer.TokenizeMarginal(s)
+			if err != nil {
+				fatal(err)
+			}
+			if *o != "" {
+				printFormat(tokenizer, s, tokens, *o)
+			} else {
+				printMarginal(tokens)
+			}
+		} else if *o != "" {
+			tokens_list, err := tokenizer.TokenizeNBestTokens(s, *n)
+			if err != nil {
+				fatal(err)
+			}
+			for _, tokens := range tokens_list {
+				printFormat(tokenizer, s, tokens, *o)
+			}
+		} else if *n > 1 {
 			morphemes_list, err := tokenizer.TokenizeNBest(s, *n)
 			if err != nil {
-				panic(err)
+				fatal(err)
 			}
 			for _, m := range morphemes_list {
 				print(m)
@@ -47,7 +124,7 @@ func main() {
 		} else {
 			morphemes, err := tokenizer.Tokenize(s)
 			if err != nil {
-				panic(err)
+				fatal(err)
 			}
 			print(morphemes)
 		}
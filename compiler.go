@@ -0,0 +1,811 @@
+/*****************************************************************************
+MIT License
+
+Copyright (c) 2020 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*****************************************************************************/
+
+package goawabi
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Double array builder (Darts compatible)
+
+type daNode struct {
+	code  int
+	depth int
+	left  int
+	right int
+}
+
+type doubleArrayBuilder struct {
+	base           []int32
+	check          []uint32
+	used           []bool
+	keys           [][]byte
+	values         []int32
+	next_check_pos int
+	size           int
+}
+
+func (b *doubleArrayBuilder) resize(n int) {
+	if n <= len(b.check) {
+		return
+	}
+	m := len(b.check) * 2
+	if m < n {
+		m = n
+	}
+	base := make([]int32, m)
+	check := make([]uint32, m)
+	used := make([]bool, m)
+	copy(base, b.base)
+	copy(check, b.check)
+	copy(used, b.used)
+	b.base, b.check, b.used = base, check, used
+}
+
+func (b *doubleArrayBuilder) fetch(parent daNode) ([]daNode, error) {
+	siblings := make([]daNode, 0)
+	prev := 0
+	for i := parent.left; i < parent.right; i++ {
+		key := b.keys[i]
+		if len(key) < parent.depth {
+			continue
+		}
+		cur := 0
+		if len(key) > parent.depth {
+			cur = int(key[parent.depth]) + 1
+		}
+		if prev > cur {
+			return nil, errors.New("double array: keys are not sorted")
+		}
+		if cur != prev || len(siblings) == 0 {
+			if len(siblings) > 0 {
+				siblings[len(siblings)-1].right = i
+			}
+			siblings = append(siblings, daNode{code: cur, depth: parent.depth + 1, left: i})
+		}
+		prev = cur
+	}
+	if len(siblings) > 0 {
+		siblings[len(siblings)-1].right = parent.right
+	}
+	return siblings, nil
+}
+
+func (b *doubleArrayBuilder) insert(siblings []daNode) (int, error) {
+	first_code := siblings[0].code
+	last_code := siblings[len(siblings)-1].code
+	pos := first_code + 1
+	if b.next_check_pos > pos {
+		pos = b.next_check_pos
+	}
+	pos--
+	begin := 0
+	nonzero_num := 0
+	first := true
+
+	for {
+		pos++
+		b.resize(pos + 1)
+		if b.check[pos] != 0 {
+			nonzero_num++
+			continue
+		} else if first {
+			b.next_check_pos = pos
+			first = false
+		}
+
+		begin = pos - first_code
+		b.resize(begin + last_code + 1)
+		if b.used[begin] {
+			continue
+		}
+		conflict := false
+		for _, s := range siblings[1:] {
+			if b.check[begin+s.code] != 0 {
+				conflict = true
+				break
+			}
+		}
+		if !conflict {
+			break
+		}
+	}
+
+	if float64(nonzero_num)/float64(pos-b.next_check_pos+1) >= 0.95 {
+		b.next_check_pos = pos
+	}
+	b.used[begin] = true
+	if b.size < begin+last_code+1 {
+		b.size = begin + last_code + 1
+	}
+
+	for _, s := range siblings {
+		b.check[begin+s.code] = uint32(begin)
+	}
+
+	for _, s := range siblings {
+		children, err := b.fetch(s)
+		if err != nil {
+			return 0, err
+		}
+		if len(children) == 0 {
+			b.base[begin+s.code] = -b.values[s.left] - 1
+		} else {
+			h, err := b.insert(children)
+			if err != nil {
+				return 0, err
+			}
+			b.base[begin+s.code] = int32(h)
+		}
+	}
+
+	return begin, nil
+}
+
+func buildDoubleArray(keys [][]byte, values []int32) ([]byte, error) {
+	// keys must be sorted and unique, returns serialized (base, check) units
+	b := new(doubleArrayBuilder)
+	b.keys = keys
+	b.values = values
+	b.resize(8192)
+	b.base[0] = 1
+	b.size = 1
+
+	if len(keys) > 0 {
+		siblings, err := b.fetch(daNode{code: 0, depth: 0, left: 0, right: len(keys)})
+		if err != nil {
+			return nil, err
+		}
+		if _, err := b.insert(siblings); err != nil {
+			return nil, err
+		}
+	}
+
+	// padding so that any transition from a used base stays in the array
+	size := b.size + 257
+	b.resize(size)
+	data := make([]byte, size*8)
+	for i := 0; i < size; i++ {
+		binary.LittleEndian.PutUint32(data[i*8:], uint32(b.base[i]))
+		binary.LittleEndian.PutUint32(data[i*8+4:], b.check[i])
+	}
+	return data, nil
+}
+
+// Dictionary source
+
+type dicSource struct {
+	surface string
+	lc_attr uint16
+	rc_attr uint16
+	posid   uint16
+	wcost   int16
+	feature string
+}
+
+func escapeCSVField(s string) string {
+	if strings.ContainsAny(s, ",\"") {
+		return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+	}
+	return s
+}
+
+func joinFeature(fields []string) string {
+	escaped := make([]string, len(fields))
+	for i, f := range fields {
+		escaped[i] = escapeCSVField(f)
+	}
+	return strings.Join(escaped, ",")
+}
+
+func readLines(path string, fn func(line string, lineno int) error) error {
+	fp, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer fp.Close()
+
+	scanner := bufio.NewScanner(fp)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	lineno := 0
+	for scanner.Scan() {
+		lineno++
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if lineno == 1 {
+			line = strings.TrimPrefix(line, "\ufeff")
+		}
+		if !utf8.ValidString(line) {
+			return fmt.Errorf("%s:%d: not UTF-8, convert source files to UTF-8 (e.g. iconv -f EUC-JP -t UTF-8)", path, lineno)
+		}
+		if err := fn(line, lineno); err != nil {
+			return fmt.Errorf("%s:%d: %w", path, lineno, err)
+		}
+	}
+	return scanner.Err()
+}
+
+func parseDicLine(line string) (*dicSource, error) {
+	fields := splitFeature(line)
+	if len(fields) < 5 {
+		return nil, errors.New("format error: " + line)
+	}
+	lc_attr, err := strconv.ParseUint(fields[1], 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid left id: %s", fields[1])
+	}
+	rc_attr, err := strconv.ParseUint(fields[2], 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid right id: %s", fields[2])
+	}
+	wcost, err := strconv.ParseInt(fields[3], 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cost: %s", fields[3])
+	}
+	if fields[0] == "" {
+		return nil, errors.New("empty surface: " + line)
+	}
+
+	d := new(dicSource)
+	d.surface = fields[0]
+	d.lc_attr = uint16(lc_attr)
+	d.rc_attr = uint16(rc_attr)
+	d.wcost = int16(wcost)
+	d.feature = joinFeature(fields[4:])
+	return d, nil
+}
+
+func readDicCSV(path string, lsize int, rsize int, pid *posIdGenerator) ([]*dicSource, error) {
+	entries := make([]*dicSource, 0)
+	err := readLines(path, func(line string, lineno int) error {
+		if line == "" {
+			return nil
+		}
+		d, err := parseDicLine(line)
+		if err != nil {
+			return err
+		}
+		// left id is a right side of the transition and vice versa
+		if int(d.lc_attr) >= rsize || int(d.rc_attr) >= lsize {
+			return fmt.Errorf("context id is out of range (%d, %d): %s", d.lc_attr, d.rc_attr, line)
+		}
+		if pid != nil {
+			d.posid = pid.id(d.feature)
+		}
+		entries = append(entries, d)
+		return nil
+	})
+	return entries, err
+}
+
+func compileDic(entries []*dicSource, lsize int, rsize int, dic_type int, charset string) ([]byte, error) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].surface < entries[j].surface
+	})
+
+	keys := make([][]byte, 0)
+	values := make([]int32, 0)
+	tokens := make([]byte, 0, len(entries)*16)
+	features := make([]byte, 0)
+	for i := 0; i < len(entries); {
+		j := i
+		for j < len(entries) && entries[j].surface == entries[i].surface {
+			j++
+		}
+		if j-i > 0xff {
+			return nil, fmt.Errorf("too many entries for %s", entries[i].surface)
+		}
+		keys = append(keys, []byte(entries[i].surface))
+		values = append(values, int32(i<<8|(j-i)))
+		i = j
+	}
+	for _, d := range entries {
+		var token [16]byte
+		binary.LittleEndian.PutUint16(token[0:], d.lc_attr)
+		binary.LittleEndian.PutUint16(token[2:], d.rc_attr)
+		binary.LittleEndian.PutUint16(token[4:], d.posid)
+		binary.LittleEndian.PutUint16(token[6:], uint16(d.wcost))
+		binary.LittleEndian.PutUint32(token[8:], uint32(len(features)))
+		tokens = append(tokens, token[:]...)
+		features = append(features, d.feature...)
+		features = append(features, 0)
+	}
+
+	da, err := buildDoubleArray(keys, values)
+	if err != nil {
+		return nil, err
+	}
+
+	size := DIC_HEADER_SIZE + len(da) + len(tokens) + len(features)
+	data := make([]byte, DIC_HEADER_SIZE, size)
+	binary.LittleEndian.PutUint32(data[0:], uint32(size)^DIC_MAGIC_ID)
+	binary.LittleEndian.PutUint32(data[4:], DIC_VERSION)
+	binary.LittleEndian.PutUint32(data[8:], uint32(dic_type))
+	binary.LittleEndian.PutUint32(data[12:], uint32(len(entries)))
+	binary.LittleEndian.PutUint32(data[16:], uint32(lsize))
+	binary.LittleEndian.PutUint32(data[20:], uint32(rsize))
+	binary.LittleEndian.PutUint32(data[24:], uint32(len(da)))
+	binary.LittleEndian.PutUint32(data[28:], uint32(len(tokens)))
+	binary.LittleEndian.PutUint32(data[32:], uint32(len(features)))
+	binary.LittleEndian.PutUint32(data[36:], 0)
+	if len(charset) >= 32 {
+		return nil, errors.New("charset name is too long: " + charset)
+	}
+	copy(data[40:72], charset)
+
+	data = append(data, da...)
+	data = append(data, tokens...)
+	data = append(data, features...)
+	return data, nil
+}
+
+// pos-id.def
+
+type posIdRule struct {
+	pattern []string
+	id      uint16
+}
+
+type posIdGenerator struct {
+	rules []posIdRule
+}
+
+func newPosIdGenerator(path string) (*posIdGenerator, error) {
+	pid := new(posIdGenerator)
+	err := readLines(path, func(line string, lineno int) error {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			return nil
+		}
+		i := strings.LastIndexAny(line, " \t")
+		if i < 0 {
+			return errors.New("format error: " + line)
+		}
+		id, err := strconv.ParseUint(line[i+1:], 10, 16)
+		if err != nil {
+			return errors.New("format error: " + line)
+		}
+		pattern := splitFeature(strings.TrimSpace(line[:i]))
+		pid.rules = append(pid.rules, posIdRule{pattern: pattern, id: uint16(id)})
+		return nil
+	})
+	return pid, err
+}
+
+func matchPosIdPattern(pattern string, field string) bool {
+	if pattern == "*" {
+		return true
+	}
+	if strings.HasPrefix(pattern, "(") && strings.HasSuffix(pattern, ")") {
+		for _, s := range strings.Split(pattern[1:len(pattern)-1], "|") {
+			if s == field {
+				return true
+			}
+		}
+		return false
+	}
+	return pattern == field
+}
+
+func (pid *posIdGenerator) id(feature string) uint16 {
+	fields := splitFeature(feature)
+	for _, rule := range pid.rules {
+		if len(fields) < len(rule.pattern) {
+			continue
+		}
+		matched := true
+		for i, pattern := range rule.pattern {
+			if !matchPosIdPattern(pattern, fields[i]) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return rule.id
+		}
+	}
+	return 0
+}
+
+// matrix.def
+
+func compileMatrix(path string) ([]byte, error) {
+	var data []byte
+	var lsize, rsize int
+	err := readLines(path, func(line string, lineno int) error {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			return nil
+		}
+		if data == nil {
+			if len(fields) != 2 {
+				return errors.New("format error: " + line)
+			}
+			l, err1 := strconv.ParseUint(fields[0], 10, 16)
+			r, err2 := strconv.ParseUint(fields[1], 10, 16)
+			if err1 != nil || err2 != nil {
+				return errors.New("format error: " + line)
+			}
+			lsize, rsize = int(l), int(r)
+			data = make([]byte, 4+lsize*rsize*2)
+			binary.LittleEndian.PutUint16(data[0:], uint16(lsize))
+			binary.LittleEndian.PutUint16(data[2:], uint16(rsize))
+			return nil
+		}
+		if len(fields) != 3 {
+			return errors.New("format error: " + line)
+		}
+		l, err1 := strconv.Atoi(fields[0])
+		r, err2 := strconv.Atoi(fields[1])
+		c, err3 := strconv.ParseInt(fields[2], 10, 16)
+		if err1 != nil || err2 != nil || err3 != nil {
+			return errors.New("format error: " + line)
+		}
+		if l < 0 || l >= lsize || r < 0 || r >= rsize {
+			return errors.New("context id is out of range: " + line)
+		}
+		binary.LittleEndian.PutUint16(data[4+(r*lsize+l)*2:], uint16(c))
+		return nil
+	})
+	if err == nil && data == nil {
+		err = errors.New(path + ": empty matrix")
+	}
+	return data, err
+}
+
+// char.def
+
+type charCategory struct {
+	id     int
+	invoke uint32
+	group  uint32
+	length uint32
+}
+
+func parseCodePoint(s string) (int, error) {
+	v, err := strconv.ParseUint(strings.TrimPrefix(s, "0x"), 16, 32)
+	if err != nil || v > 0xFFFF {
+		return 0, errors.New("invalid code point: " + s)
+	}
+	return int(v), nil
+}
+
+func compileCharProperty(path string) ([]byte, []string, error) {
+	categories := make(map[string]*charCategory)
+	names := make([]string, 0)
+	table := make([]uint32, 0x10000)
+	mappings := make([][]string, 0)
+
+	err := readLines(path, func(line string, lineno int) error {
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			return nil
+		}
+		if strings.HasPrefix(fields[0], "0x") {
+			if len(fields) < 2 {
+				return errors.New("format error: " + line)
+			}
+			mappings = append(mappings, fields)
+			return nil
+		}
+		if len(fields) != 4 {
+			return errors.New("format error: " + line)
+		}
+		var v [3]uint32
+		for i := 0; i < 3; i++ {
+			n, err := strconv.ParseUint(fields[i+1], 10, 8)
+			if err != nil {
+				return errors.New("format error: " + line)
+			}
+			v[i] = uint32(n)
+		}
+		if v[0] > 1 || v[1] > 1 || v[2] > 15 {
+			return errors.New("invalid category property: " + line)
+		}
+		if _, ok := categories[fields[0]]; ok {
+			return errors.New("category is already defined: " + fields[0])
+		}
+		if len(fields[0]) >= 32 {
+			return errors.New("category name is too long: " + fields[0])
+		}
+		if len(names) >= 18 {
+			return errors.New("too many categories: " + fields[0])
+		}
+		categories[fields[0]] = &charCategory{id: len(names), invoke: v[0], group: v[1], length: v[2]}
+		names = append(names, fields[0])
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	encode := func(cats []string) (uint32, error) {
+		var v uint32
+		for i, name := range cats {
+			c, ok := categories[name]
+			if !ok {
+				return 0, fmt.Errorf("%s: category [%s] is undefined", path, name)
+			}
+			if i == 0 {
+				v = c.invoke<<31 | c.group<<30 | c.length<<26 | uint32(c.id)<<18
+			}
+			v |= 1 << c.id
+		}
+		return v, nil
+	}
+
+	v, err := encode([]string{"DEFAULT"})
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := range table {
+		table[i] = v
+	}
+	for _, fields := range mappings {
+		r := strings.SplitN(fields[0], "..", 2)
+		low, err := parseCodePoint(r[0])
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", path, err)
+		}
+		high := low
+		if len(r) == 2 {
+			high, err = parseCodePoint(r[1])
+			if err != nil {
+				return nil, nil, fmt.Errorf("%s: %w", path, err)
+			}
+		}
+		if low > high {
+			return nil, nil, fmt.Errorf("%s: invalid range: %s", path, fields[0])
+		}
+		v, err := encode(fields[1:])
+		if err != nil {
+			return nil, nil, err
+		}
+		for c := low; c <= high; c++ {
+			table[c] = v
+		}
+	}
+
+	data := make([]byte, 4+len(names)*32+len(table)*4)
+	binary.LittleEndian.PutUint32(data, uint32(len(names)))
+	for i, name := range names {
+		copy(data[4+i*32:], name)
+	}
+	offset := 4 + len(names)*32
+	for i, v := range table {
+		binary.LittleEndian.PutUint32(data[offset+i*4:], v)
+	}
+	return data, names, nil
+}
+
+// dicrc
+
+func getDicCharset(src_dir string) (string, error) {
+	// only UTF-8 sources are supported
+	charset := "utf-8"
+	path := filepath.Join(src_dir, "dicrc")
+	if _, err := os.Stat(path); err != nil {
+		return charset, nil
+	}
+	rc, err := get_mecabrc_map(path)
+	if err == nil && rc["config-charset"] != "" {
+		charset = strings.TrimSpace(rc["config-charset"])
+	}
+	if isUTF8Charset(charset) {
+		return charset, nil
+	}
+	return "", fmt.Errorf("%s: config-charset %s is not supported, convert source files to UTF-8 and set config-charset = UTF-8", path, charset)
+}
+
+func copyFile(src string, dst string) error {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(dst, data, 0644)
+}
+
+// Compile a MeCab dictionary source directory (*.csv, matrix.def, char.def,
+// unk.def and optional dicrc, pos-id.def) into sys.dic, unk.dic, matrix.bin
+// and char.bin in out_dir. Source files must be encoded in UTF-8, a line or
+// config-charset of dicrc in other encodings is an error.
+func CompileDictionary(src_dir string, out_dir string) error {
+	charset, err := getDicCharset(src_dir)
+	if err != nil {
+		return err
+	}
+
+	matrix_data, err := compileMatrix(filepath.Join(src_dir, "matrix.def"))
+	if err != nil {
+		return err
+	}
+	lsize := int(binary.LittleEndian.Uint16(matrix_data))
+	rsize := int(binary.LittleEndian.Uint16(matrix_data[2:]))
+
+	char_data, category_names, err := compileCharProperty(filepath.Join(src_dir, "char.def"))
+	if err != nil {
+		return err
+	}
+
+	var pid *posIdGenerator
+	pid_path := filepath.Join(src_dir, "pos-id.def")
+	if _, err := os.Stat(pid_path); err == nil {
+		pid, err = newPosIdGenerator(pid_path)
+		if err != nil {
+			return err
+		}
+	}
+
+	unk_path := filepath.Join(src_dir, "unk.def")
+	unk_entries, err := readDicCSV(unk_path, lsize, rsize, pid)
+	if err != nil {
+		return err
+	}
+	for _, name := range category_names {
+		found := false
+		for _, e := range unk_entries {
+			if e.surface == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s: category [%s] is undefined", unk_path, name)
+		}
+	}
+	unk_data, err := compileDic(unk_entries, lsize, rsize, UNK_DIC, charset)
+	if err != nil {
+		return err
+	}
+
+	csv_paths, err := filepath.Glob(filepath.Join(src_dir, "*.csv"))
+	if err != nil {
+		return err
+	}
+	if len(csv_paths) == 0 {
+		return errors.New(src_dir + ": no dictionary csv file")
+	}
+	sort.Strings(csv_paths)
+	sys_entries := make([]*dicSource, 0)
+	for _, path := range csv_paths {
+		entries, err := readDicCSV(path, lsize, rsize, pid)
+		if err != nil {
+			return err
+		}
+		sys_entries = append(sys_entries, entries...)
+	}
+	sys_data, err := compileDic(sys_entries, lsize, rsize, SYS_DIC, charset)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(out_dir, 0755); err != nil {
+		return err
+	}
+	for name, data := range map[string][]byte{
+		"matrix.bin": matrix_data,
+		"char.bin":   char_data,
+		"unk.dic":    unk_data,
+		"sys.dic":    sys_data,
+	} {
+		if err := os.WriteFile(filepath.Join(out_dir, name), data, 0644); err != nil {
+			return err
+		}
+	}
+	for _, name := range []string{"dicrc", "pos-id.def"} {
+		src := filepath.Join(src_dir, name)
+		if _, err := os.Stat(src); err != nil {
+			continue
+		}
+		if filepath.Clean(src) == filepath.Clean(filepath.Join(out_dir, name)) {
+			continue
+		}
+		if err := copyFile(src, filepath.Join(out_dir, name)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func readMatrixSize(path string) (int, int, error) {
+	fp, err := os.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer fp.Close()
+
+	var header [4]byte
+	if _, err := fp.Read(header[:]); err != nil {
+		return 0, 0, fmt.Errorf("%s: %w", path, err)
+	}
+	return int(binary.LittleEndian.Uint16(header[:])), int(binary.LittleEndian.Uint16(header[2:])), nil
+}
+
+func readDicCharset(path string) (string, error) {
+	fp, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer fp.Close()
+
+	var header [DIC_HEADER_SIZE]byte
+	if _, err := fp.Read(header[:]); err != nil {
+		return "", fmt.Errorf("%s: %w", path, err)
+	}
+	return c_str_to_string(header[40:]), nil
+}
+
+// Compile user dictionary csv files into out_path like `mecab-dict-index -u`.
+// dicdir is a compiled system dictionary directory, context ids in csv files
+// are validated against its matrix.bin.
+func CompileUserDictionary(dicdir string, csv_paths []string, out_path string) error {
+	lsize, rsize, err := readMatrixSize(filepath.Join(dicdir, "matrix.bin"))
+	if err != nil {
+		return err
+	}
+	charset, err := readDicCharset(filepath.Join(dicdir, "sys.dic"))
+	if err != nil {
+		return err
+	}
+
+	var pid *posIdGenerator
+	pid_path := filepath.Join(dicdir, "pos-id.def")
+	if _, err := os.Stat(pid_path); err == nil {
+		pid, err = newPosIdGenerator(pid_path)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(csv_paths) == 0 {
+		return errors.New("no user dictionary csv file")
+	}
+	user_entries := make([]*dicSource, 0)
+	for _, path := range csv_paths {
+		entries, err := readDicCSV(path, lsize, rsize, pid)
+		if err != nil {
+			return err
+		}
+		user_entries = append(user_entries, entries...)
+	}
+	data, err := compileDic(user_entries, lsize, rsize, USR_DIC, charset)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(out_path, data, 0644)
+}
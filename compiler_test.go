@@ -0,0 +1,214 @@
+/*****************************************************************************
+MIT License
+
+Copyright (c) 2020 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*****************************************************************************/
+
+package goawabi
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func compileTestDictionary(t *testing.T) string {
+	dir := t.TempDir()
+	if err := CompileDictionary(filepath.Join("testdata", "dic"), dir); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func writeTestMecabrc(t *testing.T, dir string, rc string) string {
+	mecabrc := filepath.Join(dir, "mecabrc")
+	if err := os.WriteFile(mecabrc, []byte("dicdir = "+dir+"\n"+rc), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return mecabrc
+}
+
+func newTestTokenizer(t *testing.T) *Tokenizer {
+	dir := compileTestDictionary(t)
+	tokenizer, err := NewTokenizer(writeTestMecabrc(t, dir, ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tokenizer
+}
+
+func TestDoubleArray(t *testing.T) {
+	keys := [][]byte{[]byte("a"), []byte("ab"), []byte("abc"), []byte("b"), []byte("bcd")}
+	values := []int32{10, 20, 30, 40, 50}
+	data, err := buildDoubleArray(keys, values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &mecabDic{data: data, da_offset: 0, da_size: uint32(len(data) / 8)}
+	for i, key := range keys {
+		if v := m.exactMatchSearch(key); v != values[i] {
+			t.Errorf("exactMatchSearch(%s) = %d", key, v)
+		}
+	}
+	if v := m.exactMatchSearch([]byte("bc")); v != -1 {
+		t.Errorf("exactMatchSearch(bc) = %d", v)
+	}
+	r := m.commonPrefixSearch([]byte("abcd"))
+	if len(r) != 3 || r[2][0] != 30 || r[2][1] != 3 {
+		t.Errorf("commonPrefixSearch(abcd) = %v", r)
+	}
+}
+
+func TestCompileDictionary(t *testing.T) {
+	dir := compileTestDictionary(t)
+
+	m, err := newMatrix(filepath.Join(dir, "matrix.bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.lsize != 11 || m.rsize != 11 || m.getTransCost(1, 2) != -500 || m.getTransCost(7, 10) != -1000 {
+		t.Errorf("matrix.bin is invalid")
+	}
+
+	cp, err := newCharProperty(filepath.Join(dir, "char.bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cp.category_names) != 9 || cp.category_names[1] != "SPACE" {
+		t.Errorf("char.bin is invalid:%v", cp.category_names)
+	}
+	assertGetCharInfo(t, cp, 0, 0, 1, 0, 1, 0)        // DEFAULT
+	assertGetCharInfo(t, cp, 0x20, 1, 2, 0, 1, 0)     // SPACE
+	assertGetCharInfo(t, cp, 0x6f22, 2, 4, 2, 0, 0)   // KANJI 漢
+	assertGetCharInfo(t, cp, 0x3007, 3, 264, 0, 1, 1) // SYMBOL KANJINUMERIC
+	assertGetCharInfo(t, cp, 0x4e00, 8, 260, 0, 1, 1) // KANJINUMERIC KANJI
+
+	sys_dic, err := newMecabDic(filepath.Join(dir, "sys.dic"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fi, _ := os.Stat(filepath.Join(dir, "sys.dic"))
+	if sys_dic.dic_size != int(fi.Size()) || sys_dic.lsize != 11 || sys_dic.rsize != 11 {
+		t.Errorf("sys.dic header is invalid")
+	}
+	entries := sys_dic.lookup([]byte("すもももももももものうち"))
+	if len(entries) != 1 || entries[0].original != "すもも" || entries[0].posid != 38 {
+		t.Errorf("lookup() failed:%v", entries)
+	}
+	entries = sys_dic.lookup([]byte("1,000"))
+	if len(entries) != 1 || entries[0].feature != `名詞,数,*,*,*,*,"1,000",セン,セン` {
+		t.Errorf("lookup() failed:%v", entries)
+	}
+
+	unk_dic, err := newMecabDic(filepath.Join(dir, "unk.dic"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	unk_entries, invoke := unk_dic.lookupUnknowns([]byte("abc def"), cp, MAX_GROUPING_SIZE)
+	if len(unk_entries) != 2 || unk_entries[0].original != "abc" || !invoke {
+		t.Errorf("lookupUnknowns() failed:%v", unk_entries)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "dicrc")); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCompileNonUTF8Dictionary(t *testing.T) {
+	src := t.TempDir()
+	files, err := os.ReadDir(filepath.Join("testdata", "dic"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range files {
+		data, _ := os.ReadFile(filepath.Join("testdata", "dic", f.Name()))
+		os.WriteFile(filepath.Join(src, f.Name()), data, 0644)
+	}
+
+	// "すもも" in EUC-JP
+	os.WriteFile(filepath.Join(src, "words.csv"), []byte("\xa4\xb9\xa4\xe2\xa4\xe2,1,1,3000,名詞,一般,*,*,*,*,*\n"), 0644)
+	err = CompileDictionary(src, t.TempDir())
+	if err == nil || !strings.Contains(err.Error(), "words.csv:1: not UTF-8") {
+		t.Errorf("non UTF-8 source must be error:%v", err)
+	}
+
+	rc, _ := os.ReadFile(filepath.Join(src, "dicrc"))
+	os.WriteFile(filepath.Join(src, "dicrc"), []byte(strings.Replace(string(rc), "UTF-8", "EUC-JP", 1)), 0644)
+	err = CompileDictionary(src, t.TempDir())
+	if err == nil || !strings.Contains(err.Error(), "config-charset EUC-JP is not supported") {
+		t.Errorf("non UTF-8 config-charset must be error:%v", err)
+	}
+}
+
+func TestCompiledDictionaryTokenize(t *testing.T) {
+	tokenizer := newTestTokenizer(t)
+	morphemes, err := tokenizer.Tokenize("すもももももももものうち")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"すもも", "も", "もも", "も", "もも", "の", "うち"}
+	if len(morphemes) != len(expected) {
+		t.Fatalf("Tokenize() failed:%v", morphemes)
+	}
+	for i, m := range morphemes {
+		if m[0] != expected[i] {
+			t.Errorf("Tokenize() failed:%v", morphemes)
+		}
+	}
+}
+
+func TestCompileUserDictionary(t *testing.T) {
+	dir := compileTestDictionary(t)
+	user_dic_path := filepath.Join(dir, "user.dic")
+	err := CompileUserDictionary(dir, []string{filepath.Join("testdata", "userdic", "user.csv")}, user_dic_path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user_dic, err := newMecabDic(user_dic_path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user_dic.lsize != 11 || user_dic.rsize != 11 {
+		t.Errorf("user.dic header is invalid")
+	}
+	entries := user_dic.lookup([]byte("ハハハと"))
+	if len(entries) != 1 || entries[0].feature != "感動詞,*,*,*,*,*,ハハハ,ハハハ,ハハハ" {
+		t.Errorf("lookup() failed:%v", entries)
+	}
+
+	err = CompileUserDictionary(dir, []string{filepath.Join("testdata", "userdic", "invalid.csv")}, user_dic_path)
+	if err == nil {
+		t.Errorf("context id must be validated")
+	}
+
+	tokenizer, err := NewTokenizer(writeTestMecabrc(t, dir, "userdic = "+user_dic_path+"\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	morphemes, err := tokenizer.Tokenize("母はハハハと笑う")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if morphemes[2][0] != "ハハハ" || morphemes[2][1] != "感動詞,*,*,*,*,*,ハハハ,ハハハ,ハハハ" {
+		t.Errorf("Tokenize() with user dictionary failed:%v", morphemes)
+	}
+}
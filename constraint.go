@@ -0,0 +1,295 @@
+/*****************************************************************************
+MIT License
+
+Copyright (c) 2020 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*****************************************************************************/
+
+package goawabi
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Constrained analysis (like mecab -p)
+
+// Constraints restricts the analysis. Offsets are bytes of the input string.
+type Constraints struct {
+	Boundaries   []int            // token boundaries must be at these offsets
+	NoBoundaries []int            // token boundaries must not be at these offsets
+	Spans        []SpanConstraint // these spans must be one token
+}
+
+// SpanConstraint is a span [Start, End) which must be one token.
+// If Feature is not empty, the feature of the token must match it.
+// Feature is comma separated fields like CSV, "*" matches any field and omitted
+// trailing fields match anything, e.g. "名詞,固有名詞".
+// If no dictionary entry matches, an unknown word token of the span is made
+// with Feature as its feature.
+type SpanConstraint struct {
+	Start   int
+	End     int
+	Feature string
+}
+
+type span struct {
+	start   int
+	end     int
+	feature string
+}
+
+// constraint in offsets of the lattice string
+type constraint struct {
+	boundaries    []int // sorted
+	no_boundaries map[int]bool
+	spans         []span // sorted
+}
+
+func matchFeature(pattern string, feature string) bool {
+	if pattern == "" {
+		return true
+	}
+	fields := splitFeature(feature)
+	for i, p := range splitFeature(pattern) {
+		if p == "*" {
+			continue
+		}
+		if i >= len(fields) || fields[i] != p {
+			return false
+		}
+	}
+	return true
+}
+
+func newConstraint(constraints *Constraints, str string, s []byte, byte_pos []int32) (*constraint, error) {
+	if constraints == nil {
+		return nil, nil
+	}
+
+	// input offset to lattice string offset at grapheme cluster boundaries
+	offsets := make(map[int]int)
+	for i := 0; i <= len(s); {
+		k := i
+		if byte_pos != nil {
+			k = int(byte_pos[i+1])
+		}
+		offsets[k] = i
+		if i == len(s) {
+			break
+		}
+		i += graphemeLength(s[i:])
+	}
+	offset := func(k int) (int, error) {
+		if k < 0 || k > len(str) {
+			return 0, fmt.Errorf("%w: offset %d is out of range", ErrConstraint, k)
+		}
+		i, ok := offsets[k]
+		if !ok {
+			return 0, fmt.Errorf("%w: offset %d is not a character boundary", ErrConstraint, k)
+		}
+		return i, nil
+	}
+
+	c := new(constraint)
+	c.boundaries = make([]int, 0, len(constraints.Boundaries))
+	for _, k := range constraints.Boundaries {
+		i, err := offset(k)
+		if err != nil {
+			return nil, err
+		}
+		c.boundaries = append(c.boundaries, i)
+	}
+	sort.Ints(c.boundaries)
+
+	c.no_boundaries = make(map[int]bool)
+	for _, k := range constraints.NoBoundaries {
+		if k < 0 || k > len(str) {
+			return nil, fmt.Errorf("%w: offset %d is out of range", ErrConstraint, k)
+		}
+		if k == 0 || k == len(str) {
+			return nil, fmt.Errorf("%w: offset %d is always a boundary", ErrConstraint, k)
+		}
+		if i, ok := offsets[k]; ok {
+			c.no_boundaries[i] = true
+		}
+	}
+
+	c.spans = make([]span, 0, len(constraints.Spans))
+	for _, sc := range constraints.Spans {
+		start, err := offset(sc.Start)
+		if err != nil {
+			return nil, err
+		}
+		end, err := offset(sc.End)
+		if err != nil {
+			return nil, err
+		}
+		if start >= end {
+			return nil, fmt.Errorf("%w: empty span [%d, %d)", ErrConstraint, sc.Start, sc.End)
+		}
+		c.spans = append(c.spans, span{start, end, sc.Feature})
+	}
+	sort.Slice(c.spans, func(i, j int) bool { return c.spans[i].start < c.spans[j].start })
+
+	// contradictions
+	for i, sp := range c.spans {
+		if i > 0 && c.spans[i-1].end > sp.start {
+			return nil, fmt.Errorf("%w: spans overlap", ErrConstraint)
+		}
+		if c.no_boundaries[sp.start] || c.no_boundaries[sp.end] {
+			return nil, fmt.Errorf("%w: span [%d, %d) has a forbidden boundary", ErrConstraint, sp.start, sp.end)
+		}
+	}
+	for _, i := range c.boundaries {
+		if c.no_boundaries[i] {
+			return nil, fmt.Errorf("%w: boundary %d is forced and forbidden", ErrConstraint, i)
+		}
+		for _, sp := range c.spans {
+			if sp.start < i && i < sp.end {
+				return nil, fmt.Errorf("%w: boundary %d is inside a span", ErrConstraint, i)
+			}
+		}
+	}
+	return c, nil
+}
+
+func (c *constraint) allows(start int, ln int, feature string) bool {
+	// whether a node of [start, start + ln) violates constraints
+	if c == nil {
+		return true
+	}
+	end := start + ln
+	if i := sort.SearchInts(c.boundaries, start+1); i < len(c.boundaries) && c.boundaries[i] < end {
+		return false
+	}
+	if c.no_boundaries[start] || c.no_boundaries[end] {
+		return false
+	}
+	for _, sp := range c.spans {
+		if sp.start >= end {
+			break
+		}
+		if start == sp.start && end == sp.end {
+			return matchFeature(sp.feature, feature)
+		}
+		if start < sp.end && sp.start < end {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *constraint) spanAt(start int) *span {
+	for i := range c.spans {
+		if c.spans[i].start == start {
+			return &c.spans[i]
+		}
+	}
+	return nil
+}
+
+func (tok *Tokenizer) addConstrainedNodes(lat *Lattice, c *constraint, s []byte, pos int) error {
+	// add unknown word nodes if all nodes at pos are pruned
+	if len(lat.snodes[lat.p]) > 0 {
+		return nil
+	}
+	if sp := c.spanAt(pos); sp != nil {
+		entries := tok.unknownEntries(s[pos:sp.end])
+		added := false
+		for _, entry := range entries {
+			if matchFeature(sp.feature, entry.feature) {
+				entry.skip = false
+				lat.add(newNode(entry), tok.m)
+				added = true
+			}
+		}
+		if !added {
+			if len(entries) == 0 {
+				return fmt.Errorf("%w: no unknown word entry at offset %d", ErrConstraint, lat.byteOffset(lat.p))
+			}
+			entry := entries[0]
+			entry.feature = sp.feature
+			entry.skip = false
+			lat.add(newNode(entry), tok.m)
+		}
+		return nil
+	}
+
+	// the shortest unknown word which satisfies constraints
+	for end := pos + graphemeLength(s[pos:]); end <= len(s); end += graphemeLength(s[end:]) {
+		if c.no_boundaries[end] {
+			continue
+		}
+		if !c.allows(pos, end-pos, "") {
+			break
+		}
+		for _, entry := range tok.unknownEntries(s[pos:end]) {
+			if tok.whitespace == WhitespaceToken {
+				entry.skip = false
+			}
+			lat.add(newNode(entry), tok.m)
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: no token at offset %d", ErrConstraint, lat.byteOffset(lat.p))
+}
+
+func (tok *Tokenizer) unknownEntries(s []byte) []*DicEntry {
+	if isInvalidUTF8(s, 0) && len(s) == 1 {
+		return tok.unk_dic.lookupInvalidByte(s)
+	}
+	ch32, _ := utf8ToUcs4(s, 0)
+	default_type, _, _, _, _ := tok.cp.getCharInfo(ch32)
+	return tok.unk_dic.unknownEntries(s, default_type, tok.cp)
+}
+
+// BuildConstrainedLattice returns the lattice of str like BuildLattice(),
+// nodes which violate c are pruned.
+func (tok *Tokenizer) BuildConstrainedLattice(str string, c Constraints) (lat *Lattice, err error) {
+	if err := tok.acquire(); err != nil {
+		return nil, err
+	}
+	defer tok.release()
+	defer recoverError(&err)
+
+	lat, err = tok.buildLattice(str, &c)
+	if err != nil {
+		return nil, err
+	}
+	lat.tok = tok
+	return lat, nil
+}
+
+// TokenizeConstrained returns the best tokens of str which satisfy c.
+// ErrConstraint is returned if c is invalid or can't be satisfied.
+func (tok *Tokenizer) TokenizeConstrained(str string, c Constraints) (tokens []Token, err error) {
+	if err := tok.acquire(); err != nil {
+		return nil, err
+	}
+	defer tok.release()
+	defer recoverError(&err)
+
+	lat, err := tok.buildLattice(str, &c)
+	if err != nil {
+		return nil, err
+	}
+	return lat.viterbi(tok.m)
+}
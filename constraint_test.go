@@ -0,0 +1,137 @@
+/*****************************************************************************
+MIT License
+
+Copyright (c) 2020 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*****************************************************************************/
+
+package goawabi
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestTokenizeConstrained(t *testing.T) {
+	tokenizer := newTestTokenizer(t)
+	s := "すもももももももものうち"
+	surfaces := func(tokens []Token) string {
+		r := ""
+		for _, token := range tokens {
+			r += token.Surface + "|"
+		}
+		return r
+	}
+
+	expected, _ := tokenizer.TokenizeTokens(s)
+	tokens, err := tokenizer.TokenizeConstrained(s, Constraints{})
+	if err != nil || fmt.Sprint(tokens) != fmt.Sprint(expected) {
+		t.Errorf("TokenizeConstrained() without constraints failed:%v %v", tokens, err)
+	}
+
+	tokens, err = tokenizer.TokenizeConstrained(s, Constraints{Boundaries: []int{3, 24}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if surfaces(tokens) != "す|も|も|もも|も|もも|も|の|うち|" || tokens[0].Status != UnknownToken {
+		t.Errorf("Boundaries failed:%s", surfaces(tokens))
+	}
+
+	tokens, err = tokenizer.TokenizeConstrained(s, Constraints{NoBoundaries: []int{9}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, token := range tokens {
+		if token.End == 9 {
+			t.Errorf("NoBoundaries failed:%s", surfaces(tokens))
+		}
+	}
+
+	tokens, err = tokenizer.TokenizeConstrained(s, Constraints{Spans: []SpanConstraint{
+		{Start: 0, End: 9, Feature: "名詞,固有名詞"},
+		{Start: 9, End: 15, Feature: "名詞,*,*"},
+		{Start: 24, End: 30},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if surfaces(tokens) != "すもも|もも|も|もも|もの|うち|" {
+		t.Fatalf("Spans failed:%s", surfaces(tokens))
+	}
+	if tokens[0].Feature != "名詞,固有名詞" || tokens[0].Status != UnknownToken {
+		t.Errorf("span of unmatched feature failed:%v", tokens[0])
+	}
+	if tokens[1].Feature != "名詞,一般,*,*,*,*,もも,モモ,モモ" || tokens[1].Status != NormalToken {
+		t.Errorf("span of matched feature failed:%v", tokens[1])
+	}
+	if tokens[4].Start != 24 || tokens[4].End != 30 || tokens[4].Status != UnknownToken {
+		t.Errorf("span of unknown word failed:%v", tokens[4])
+	}
+
+	// quoted field with comma
+	tokens, err = tokenizer.TokenizeConstrained("1,000年", Constraints{Spans: []SpanConstraint{
+		{Start: 0, End: 5, Feature: "*,*,*,*,*,*,*,セン"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tokens[0].Surface != "1,000" || tokens[0].Status != NormalToken || tokens[0].Features[6] != "1,000" {
+		t.Errorf("span of quoted feature failed:%v", tokens[0])
+	}
+	if !matchFeature(`名詞,数,*,*,*,*,"1,000",セン`, tokens[0].Feature) {
+		t.Errorf("matchFeature() of quoted pattern failed")
+	}
+
+	for _, c := range []Constraints{
+		{Boundaries: []int{1}},
+		{Boundaries: []int{-1}},
+		{NoBoundaries: []int{len(s)}},
+		{Boundaries: []int{9}, NoBoundaries: []int{9}},
+		{Boundaries: []int{6}, Spans: []SpanConstraint{{Start: 3, End: 9}}},
+		{Spans: []SpanConstraint{{Start: 3, End: 9}, {Start: 6, End: 12}}},
+		{Spans: []SpanConstraint{{Start: 3, End: 3}}},
+		{Spans: []SpanConstraint{{Start: 3, End: 9}}, NoBoundaries: []int{9}},
+	} {
+		if _, err := tokenizer.TokenizeConstrained(s, c); !errors.Is(err, ErrConstraint) {
+			t.Errorf("%v must be ErrConstraint:%v", c, err)
+		}
+	}
+
+	// offsets are of the input with InvalidUTF8Replace
+	tokenizer.SetInvalidUTF8Policy(InvalidUTF8Replace)
+	tokens, err = tokenizer.TokenizeConstrained("\xffすもも", Constraints{Spans: []SpanConstraint{{Start: 1, End: 7}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if surfaces(tokens) != "�|すも|も|" || tokens[1].Start != 1 || tokens[1].End != 7 {
+		t.Errorf("Spans with InvalidUTF8Replace failed:%v", tokens)
+	}
+
+	lat, err := tokenizer.BuildConstrainedLattice(s, Constraints{Boundaries: []int{9}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, node := range lat.Nodes() {
+		if node.Start() < 9 && node.End() > 9 {
+			t.Errorf("BuildConstrainedLattice() failed:%s", node.Surface())
+		}
+	}
+}
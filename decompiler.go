@@ -0,0 +1,208 @@
+/*****************************************************************************
+MIT License
+
+Copyright (c) 2020 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*****************************************************************************/
+
+package goawabi
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func (m *mecabDic) enumerate(fn func(key []byte, value int32) error) error {
+	// walk the double array in key order
+	da_size := int64(m.da_size)
+	var walk func(b int32, key []byte) error
+	walk = func(b int32, key []byte) error {
+		for c := 0; c <= 256; c++ {
+			p := int64(b) + int64(c)
+			if p < 0 || p >= da_size {
+				continue
+			}
+			base, check := m.baseCheck(uint32(p))
+			if int32(check) != b {
+				continue
+			}
+			if c == 0 {
+				if base < 0 {
+					if err := fn(key, -base-1); err != nil {
+						return err
+					}
+				}
+			} else if err := walk(base, append(key, byte(c-1))); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	b, _ := m.baseCheck(0)
+	return walk(b, make([]byte, 0, 64))
+}
+
+func writeDicEntry(w io.Writer, e *DicEntry) error {
+	_, err := fmt.Fprintf(w, "%s,%d,%d,%d,%s\n", escapeCSVField(e.original), e.lc_attr, e.rc_attr, e.wcost, e.feature)
+	return err
+}
+
+// Write sys.dic, unk.dic or user dictionary entries as MeCab source csv.
+func DumpDictionary(path string, w io.Writer) error {
+	m, err := newMecabDic(path)
+	if err != nil {
+		return err
+	}
+	defer m.close()
+	bw := bufio.NewWriter(w)
+	err = m.enumerate(func(key []byte, value int32) error {
+		for _, e := range m.getEntries(int(value), string(key), false) {
+			if err := writeDicEntry(bw, e); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// Write matrix.bin as matrix.def.
+func DumpMatrix(path string, w io.Writer) error {
+	m, err := newMatrix(path)
+	if err != nil {
+		return err
+	}
+	defer m.close()
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "%d %d\n", m.lsize, m.rsize)
+	for l := 0; l < m.lsize; l++ {
+		for r := 0; r < m.rsize; r++ {
+			fmt.Fprintf(bw, "%d %d %d\n", l, r, m.getTransCost(l, r))
+		}
+	}
+	return bw.Flush()
+}
+
+// Write char.bin as char.def.
+// Properties of a category which is never a default category are written as 0.
+func DumpCharProperty(path string, w io.Writer) error {
+	cp, err := newCharProperty(path)
+	if err != nil {
+		return err
+	}
+	defer cp.close()
+
+	charInfo := func(code_point int) uint32 {
+		return binary.LittleEndian.Uint32(cp.data[cp.offset+code_point*4:])
+	}
+	props := make([]string, len(cp.category_names))
+	for i := range props {
+		props[i] = "0 0 0"
+	}
+	for c := 0xFFFF; c >= 0; c-- {
+		default_type, _, count, group, invoke := cp.getCharInfo(uint32(c))
+		if int(default_type) < len(props) {
+			props[default_type] = fmt.Sprintf("%d %d %d", invoke, group, count)
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	for i, name := range cp.category_names {
+		fmt.Fprintf(bw, "%s %s\n", name, props[i])
+	}
+	fmt.Fprintf(bw, "\n")
+
+	for c := 0; c <= 0xFFFF; {
+		v := charInfo(c)
+		end := c
+		for end < 0xFFFF && charInfo(end+1) == v {
+			end++
+		}
+		default_type, char_type, _, _, _ := cp.getCharInfo(uint32(c))
+		if default_type != 0 || char_type != 1 {
+			names := []string{cp.category_names[default_type]}
+			for i, name := range cp.category_names {
+				if uint32(i) != default_type && char_type&(1<<i) != 0 {
+					names = append(names, name)
+				}
+			}
+			if end == c {
+				fmt.Fprintf(bw, "0x%04X %s\n", c, strings.Join(names, " "))
+			} else {
+				fmt.Fprintf(bw, "0x%04X..0x%04X %s\n", c, end, strings.Join(names, " "))
+			}
+		}
+		c = end + 1
+	}
+	return bw.Flush()
+}
+
+func dumpFile(dump func(string, io.Writer) error, src string, dst string) error {
+	fp, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if err := dump(src, fp); err != nil {
+		fp.Close()
+		return err
+	}
+	return fp.Close()
+}
+
+// Decompile a compiled dictionary directory into MeCab source files
+// (sys.csv, unk.def, matrix.def, char.def) which CompileDictionary accepts.
+func DecompileDictionary(dicdir string, out_dir string) error {
+	if err := os.MkdirAll(out_dir, 0755); err != nil {
+		return err
+	}
+	for _, v := range []struct {
+		dump func(string, io.Writer) error
+		src  string
+		dst  string
+	}{
+		{DumpDictionary, "sys.dic", "sys.csv"},
+		{DumpDictionary, "unk.dic", "unk.def"},
+		{DumpMatrix, "matrix.bin", "matrix.def"},
+		{DumpCharProperty, "char.bin", "char.def"},
+	} {
+		if err := dumpFile(v.dump, filepath.Join(dicdir, v.src), filepath.Join(out_dir, v.dst)); err != nil {
+			return err
+		}
+	}
+	for _, name := range []string{"dicrc", "pos-id.def"} {
+		src := filepath.Join(dicdir, name)
+		if _, err := os.Stat(src); err != nil {
+			continue
+		}
+		if err := copyFile(src, filepath.Join(out_dir, name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+/*****************************************************************************
+MIT License
+
+Copyright (c) 2020 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*****************************************************************************/
+
+package goawabi
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEnumerate(t *testing.T) {
+	dir := compileTestDictionary(t)
+	sys_dic, err := newMecabDic(filepath.Join(dir, "sys.dic"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := make([]string, 0)
+	err = sys_dic.enumerate(func(key []byte, value int32) error {
+		keys = append(keys, string(key))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 13 || keys[0] != "1,000" || keys[len(keys)-1] != "葛" {
+		t.Errorf("enumerate() failed:%v", keys)
+	}
+}
+
+func TestDumpDictionary(t *testing.T) {
+	dir := compileTestDictionary(t)
+
+	var buf bytes.Buffer
+	if err := DumpDictionary(filepath.Join(dir, "unk.dic"), &buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "ALPHA,8,8,4000,名詞,固有名詞,組織,*,*,*,*\nALPHA,1,1,4500,名詞,一般,*,*,*,*,*\n") {
+		t.Errorf("DumpDictionary() failed:%s", buf.String())
+	}
+
+	buf.Reset()
+	if err := DumpCharProperty(filepath.Join(dir, "char.bin"), &buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"KANJI 0 0 2\n", "0x0030..0x0039 NUMERIC\n", "0x3007 SYMBOL KANJINUMERIC\n"} {
+		if !strings.Contains(buf.String(), s) {
+			t.Errorf("DumpCharProperty() failed:%s", s)
+		}
+	}
+
+	// decompile and compile again
+	src_dir := filepath.Join(t.TempDir(), "src")
+	if err := DecompileDictionary(dir, src_dir); err != nil {
+		t.Fatal(err)
+	}
+	out_dir := filepath.Join(t.TempDir(), "out")
+	if err := CompileDictionary(src_dir, out_dir); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"sys.dic", "unk.dic", "matrix.bin", "char.bin"} {
+		data1, _ := os.ReadFile(filepath.Join(dir, name))
+		data2, _ := os.ReadFile(filepath.Join(out_dir, name))
+		if !bytes.Equal(data1, data2) {
+			t.Errorf("%s is not same after decompile", name)
+		}
+	}
+}
@@ -26,12 +26,28 @@ package goawabi
 
 import (
 	"encoding/binary"
+	"fmt"
+	"io"
+	"io/fs"
 	"os"
-	"syscall"
+	"path/filepath"
+	"unicode/utf8"
 )
 
 const MAX_GROUPING_SIZE = 24
 
+const (
+	DIC_MAGIC_ID    = 0xef718f77
+	DIC_VERSION     = 102
+	DIC_HEADER_SIZE = 72
+
+	SYS_DIC = 0
+	USR_DIC = 1
+	UNK_DIC = 2
+
+	CHAR_TABLE_SIZE = 0x10000
+)
+
 type DicEntry struct {
 	original string
 	lc_attr  uint16
@@ -39,94 +55,212 @@ type DicEntry struct {
 	posid    uint16
 	wcost    int16
 	feature  string
+	stat     TokenStatus
 	skip     bool
+	dic      string
+}
+
+// Dictionary file loading
+
+type fileOpener func(path string) (data []byte, mapped bool, err error)
+
+func readOSFile(f *os.File) ([]byte, bool, error) {
+	// mmap if possible, otherwise read into memory
+	if data, err := mmapOSFile(f); err == nil {
+		return data, true, nil
+	}
+	data, err := io.ReadAll(f)
+	return data, false, err
+}
+
+func openFile(path string) ([]byte, bool, error) {
+	f, err := os.Open(path)
+
+	if err != nil {
+		return nil, false, err
+	}
+	defer f.Close()
+	return readOSFile(f)
+}
+
+func fsOpener(fsys fs.FS) fileOpener {
+	// mmap if fsys returns *os.File (e.g. os.DirFS), otherwise read into memory
+	return func(path string) ([]byte, bool, error) {
+		f, err := fsys.Open(filepath.ToSlash(path))
+		if err != nil {
+			return nil, false, err
+		}
+		defer f.Close()
+		if osf, ok := f.(*os.File); ok {
+			return readOSFile(osf)
+		}
+		data, err := io.ReadAll(f)
+		return data, false, err
+	}
+}
+
+func closeData(data []byte, mapped bool) error {
+	if mapped {
+		return munmap(data)
+	}
+	return nil
 }
 
 func c_str_to_string(data []byte) string {
 	i := 0
-	for data[i] != 0 {
+	for i < len(data) && data[i] != 0 {
 		i++
 	}
 	return string(data[:i])
 }
 
-func utf8ToUcs2(s []byte, index int) (uint16, int) {
-	// utf8 to ucs2(16bit) code and it's array size
-	ln := 0
-
-	if (s[index] & 0b10000000) == 0b00000000 {
-		ln = 1
-	} else if (s[index] & 0b11100000) == 0b11000000 {
-		ln = 2
-	} else if (s[index] & 0b11110000) == 0b11100000 {
-		ln = 3
-	} else if (s[index] & 0b11111000) == 0b11110000 {
-		ln = 4
-	}
-
-	var ch32 uint32
-	switch ln {
-	case 1:
-		ch32 = uint32(s[index+0])
-	case 2:
-		ch32 = uint32(s[index+0]&0x1F) << 6
-		ch32 |= uint32(s[index+1] & 0x3F)
-	case 3:
-		ch32 = uint32(s[index+0]&0x0F) << 12
-		ch32 |= uint32(s[index+1]&0x3F) << 6
-		ch32 |= uint32(s[index+2] & 0x3F)
-	case 4:
-		ch32 = uint32(s[index+0]&0x07) << 18
-		ch32 |= uint32(s[index+1]&0x3F) << 12
-		ch32 |= uint32(s[index+2]&0x3F) << 6
-		ch32 |= uint32(s[index+3] & 0x03F)
-	}
-
-	// ucs4 to ucs2
-	var ch16 uint16
-	if ch32 < 0x10000 {
-		ch16 = uint16(ch32)
-	} else {
-		ch16 = uint16((((ch32-0x10000)/0x400 + 0xD800) << 8) + ((ch32-0x10000)%0x400 + 0xDC00))
-	}
-	return ch16, ln
+func utf8ToUcs4(s []byte, index int) (uint32, int) {
+	// utf8 to ucs4(code point) and it's array size,
+	// invalid or truncated sequence is a replacement character of 1 byte
+	r, ln := utf8.DecodeRune(s[index:])
+	return uint32(r), ln
+}
+
+func isInvalidUTF8(s []byte, index int) bool {
+	r, ln := utf8.DecodeRune(s[index:])
+	return r == utf8.RuneError && ln == 1
 }
 
 // CharProperty
 
+type charRange struct {
+	low  uint32
+	high uint32
+	info uint32
+}
+
 type charProperty struct {
 	data           []byte
+	mapped         bool
 	category_names []string
+	category_info  []uint32 // char info of each category as a default type
+	ext_ranges     []charRange
+	ext_default    uint32 // char info out of BMP and ext_ranges
 	offset         int
 }
 
-func newCharProperty(path string) (cp *charProperty, err error) {
-	f, err := os.Open(path)
+func newCharProperty(path string) (*charProperty, error) {
+	return newCharPropertyWithOpener(path, openFile)
+}
 
-	if err != nil {
-		return nil, err
-	}
-	defer f.Close()
-	finfo, _ := f.Stat()
-	data, err := syscall.Mmap(int(f.Fd()), 0, int(finfo.Size()), syscall.PROT_READ, syscall.MAP_PRIVATE)
+func newCharPropertyWithOpener(path string, open fileOpener) (cp *charProperty, err error) {
+	data, mapped, err := open(path)
 	if err != nil {
 		return nil, err
 	}
 
 	cp = new(charProperty)
 	cp.data = data
+	cp.mapped = mapped
+	if err := cp.parse(); err != nil {
+		cp.close()
+		return nil, corruptError(path, err)
+	}
+
+	return cp, err
+}
+
+func (cp *charProperty) parse() error {
+	if len(cp.data) < 4 {
+		return fmt.Errorf("char.bin is too short (%d bytes)", len(cp.data))
+	}
 	category_size := int(binary.LittleEndian.Uint32(cp.data))
+	if category_size == 0 || category_size > 18 {
+		return fmt.Errorf("invalid category size %d", category_size)
+	}
+	if len(cp.data) != 4+category_size*32+CHAR_TABLE_SIZE*4 {
+		return fmt.Errorf("invalid char.bin size %d for %d categories", len(cp.data), category_size)
+	}
 	cp.category_names = make([]string, category_size)
 	for i := 0; i < category_size; i++ {
 		cp.category_names[i] = c_str_to_string(cp.data[4+i*32 : 4+(i+1)*32])
 	}
 	cp.offset = 4 + category_size*32
 
-	return cp, err
+	for c := 0; c < CHAR_TABLE_SIZE; c++ {
+		v := binary.LittleEndian.Uint32(cp.data[cp.offset+c*4:])
+		default_type, char_type, _, _, _ := decodeCharInfo(v)
+		if int(default_type) >= category_size || char_type>>category_size != 0 {
+			return fmt.Errorf("invalid category of code point 0x%04X", c)
+		}
+	}
+
+	cp.category_info = make([]uint32, category_size)
+	for i := range cp.category_info {
+		cp.category_info[i] = uint32(i)<<18 | 1<<i
+	}
+	for c := CHAR_TABLE_SIZE - 1; c >= 0; c-- {
+		v := binary.LittleEndian.Uint32(cp.data[cp.offset+c*4:])
+		default_type := (v >> 18) & 0b11111111
+		cp.category_info[default_type] = v&0xFC000000 | default_type<<18 | 1<<default_type
+	}
+
+	// CJK Unified Ideographs Extension B and later are KANJI,
+	// other characters out of BMP (e.g. emoji) are EMOJI or SYMBOL
+	cp.ext_default = cp.category_info[0]
+	for _, name := range []string{"EMOJI", "SYMBOL"} {
+		if info, ok := cp.categoryInfo(name); ok {
+			cp.ext_default = info
+			break
+		}
+	}
+	if info, ok := cp.categoryInfo("KANJI"); ok {
+		cp.ext_ranges = append(cp.ext_ranges, charRange{0x20000, 0x3FFFF, info})
+	}
+	return nil
+}
+
+func (cp *charProperty) categoryInfo(name string) (uint32, bool) {
+	for i, s := range cp.category_names {
+		if s == name {
+			return cp.category_info[i], true
+		}
+	}
+	return 0, false
+}
+
+// map code points out of BMP (low..high) to a category
+func (cp *charProperty) setCategory(low uint32, high uint32, category string) error {
+	if low < CHAR_TABLE_SIZE || low > high || high > 0x10FFFF {
+		return fmt.Errorf("invalid code point range 0x%X..0x%X", low, high)
+	}
+	info, ok := cp.categoryInfo(category)
+	if !ok {
+		return fmt.Errorf("category [%s] is undefined", category)
+	}
+	// later setting takes priority
+	cp.ext_ranges = append([]charRange{{low, high, info}}, cp.ext_ranges...)
+	return nil
 }
 
-func (cp *charProperty) getCharInfo(code_point uint16) (uint32, uint32, uint32, uint32, uint32) {
-	v := binary.LittleEndian.Uint32(cp.data[cp.offset+int(code_point)*4:])
+func (cp *charProperty) close() error {
+	err := closeData(cp.data, cp.mapped)
+	cp.data = nil
+	return err
+}
+
+func (cp *charProperty) getCharInfo(code_point uint32) (uint32, uint32, uint32, uint32, uint32) {
+	var v uint32
+	if code_point < CHAR_TABLE_SIZE {
+		v = binary.LittleEndian.Uint32(cp.data[cp.offset+int(code_point)*4:])
+	} else {
+		v = cp.ext_default
+		for _, r := range cp.ext_ranges {
+			if r.low <= code_point && code_point <= r.high {
+				v = r.info
+				break
+			}
+		}
+	}
+	return decodeCharInfo(v)
+}
+
+func decodeCharInfo(v uint32) (uint32, uint32, uint32, uint32, uint32) {
 	default_type := (v >> 18) & 0b11111111
 	char_type := v & 0b111111111111111111
 	char_count := (v >> 26) & 0b1111
@@ -135,17 +269,21 @@ func (cp *charProperty) getCharInfo(code_point uint16) (uint32, uint32, uint32,
 	return default_type, char_type, char_count, group, invoke
 }
 
-func (cp *charProperty) getGroupLength(s []byte, default_type uint32) int {
+func (cp *charProperty) getGroupLength(s []byte, default_type uint32, max_grouping_size int) int {
 	var i, char_count int
 
 	for i < len(s) {
-		ch16, ln := utf8ToUcs2(s, i)
-		_, t, _, _, _ := cp.getCharInfo(ch16)
+		if isInvalidUTF8(s, i) {
+			break
+		}
+		ch32, _ := utf8ToUcs4(s, i)
+		ln := graphemeLength(s[i:])
+		_, t, _, _, _ := cp.getCharInfo(ch32)
 
 		if ((1 << default_type) & t) != 0 {
 			i += ln
 			char_count += 1
-			if char_count > MAX_GROUPING_SIZE+1 {
+			if char_count > max_grouping_size+1 {
 				return -1
 			}
 		} else {
@@ -159,11 +297,12 @@ func (cp *charProperty) getCountLength(s []byte, default_type uint32, count int)
 	var i int
 
 	for j := 0; j < count; j++ {
-		if i >= len(s) {
+		if i >= len(s) || isInvalidUTF8(s, i) {
 			return -1
 		}
-		ch16, ln := utf8ToUcs2(s, i)
-		_, t, _, _, _ := cp.getCharInfo(ch16)
+		ch32, _ := utf8ToUcs4(s, i)
+		ln := graphemeLength(s[i:])
+		_, t, _, _, _ := cp.getCharInfo(ch32)
 		if ((1 << default_type) & t) == 0 {
 			return -1
 		}
@@ -173,13 +312,14 @@ func (cp *charProperty) getCountLength(s []byte, default_type uint32, count int)
 	return i
 }
 
-func (cp *charProperty) getUnknownLengths(s []byte) (uint32, []int, bool) {
+func (cp *charProperty) getUnknownLengths(s []byte, max_grouping_size int) (uint32, []int, bool) {
 	// get unknown word bytes length vector
 	ln_list := make([]int, 0)
-	ch16, first_ln := utf8ToUcs2(s, 0)
-	default_type, _, count, group, invoke := cp.getCharInfo(ch16)
+	ch32, _ := utf8ToUcs4(s, 0)
+	first_ln := graphemeLength(s)
+	default_type, _, count, group, invoke := cp.getCharInfo(ch32)
 	if group != 0 {
-		ln := cp.getGroupLength(s, default_type)
+		ln := cp.getGroupLength(s, default_type, max_grouping_size)
 		if ln > 0 {
 			ln_list = append(ln_list, ln)
 		}
@@ -204,42 +344,101 @@ func (cp *charProperty) getUnknownLengths(s []byte) (uint32, []int, bool) {
 // MecabDic
 
 type mecabDic struct {
+	path           string
+	name           string // Token.Dictionary
 	data           []byte
+	mapped         bool
 	dic_size       int
+	dic_type       int
+	charset        string // empty if not specified
+	lexsize        int
 	lsize          int
 	rsize          int
 	da_offset      int
+	da_size        uint32
 	token_offset   int
 	feature_offset int
 }
 
-func newMecabDic(path string) (m *mecabDic, err error) {
-	f, err := os.Open(path)
+func newMecabDic(path string) (*mecabDic, error) {
+	return newMecabDicWithOpener(path, openFile)
+}
 
-	if err != nil {
-		return nil, err
-	}
-	defer f.Close()
-	finfo, _ := f.Stat()
-	data, err := syscall.Mmap(int(f.Fd()), 0, int(finfo.Size()), syscall.PROT_READ, syscall.MAP_PRIVATE)
+func newMecabDicWithOpener(path string, open fileOpener) (m *mecabDic, err error) {
+	data, mapped, err := open(path)
 	if err != nil {
 		return nil, err
 	}
 
 	m = new(mecabDic)
+	m.path = path
 	m.data = data
-	m.dic_size = int(binary.LittleEndian.Uint32(m.data[0:]) ^ 0xef718f77)
+	m.mapped = mapped
+	if err := m.parse(); err != nil {
+		m.close()
+		return nil, corruptError(path, err)
+	}
+
+	return m, err
+}
+
+func (m *mecabDic) parse() error {
+	if len(m.data) < DIC_HEADER_SIZE {
+		return fmt.Errorf("dictionary is too short (%d bytes)", len(m.data))
+	}
+	m.dic_size = int(binary.LittleEndian.Uint32(m.data[0:]) ^ DIC_MAGIC_ID)
+	if m.dic_size != len(m.data) {
+		return fmt.Errorf("invalid magic number or file size (%d != %d)", m.dic_size, len(m.data))
+	}
+	if version := binary.LittleEndian.Uint32(m.data[4:]); version != DIC_VERSION {
+		return fmt.Errorf("incompatible version %d", version)
+	}
+	m.dic_type = int(binary.LittleEndian.Uint32(m.data[8:]))
+	m.charset = c_str_to_string(m.data[40:72])
+	m.lexsize = int(binary.LittleEndian.Uint32(m.data[12:]))
 	m.lsize = int(binary.LittleEndian.Uint32(m.data[16:]))
 	m.rsize = int(binary.LittleEndian.Uint32(m.data[20:]))
-	m.da_offset = 72
-	m.token_offset = m.da_offset + int(binary.LittleEndian.Uint32(m.data[24:]))
-	m.feature_offset = m.token_offset + int(binary.LittleEndian.Uint32(m.data[28:]))
+	dsize := int64(binary.LittleEndian.Uint32(m.data[24:]))
+	tsize := int64(binary.LittleEndian.Uint32(m.data[28:]))
+	fsize := int64(binary.LittleEndian.Uint32(m.data[32:]))
+	if DIC_HEADER_SIZE+dsize+tsize+fsize != int64(len(m.data)) {
+		return fmt.Errorf("invalid section sizes (%d, %d, %d)", dsize, tsize, fsize)
+	}
+	if dsize%8 != 0 || dsize == 0 {
+		return fmt.Errorf("invalid double array size %d", dsize)
+	}
+	if tsize != int64(m.lexsize)*16 {
+		return fmt.Errorf("invalid token size %d for %d entries", tsize, m.lexsize)
+	}
+	m.da_offset = DIC_HEADER_SIZE
+	m.da_size = uint32(dsize / 8)
+	m.token_offset = m.da_offset + int(dsize)
+	m.feature_offset = m.token_offset + int(tsize)
+
+	for i := 0; i < m.lexsize; i++ {
+		feature := int64(binary.LittleEndian.Uint32(m.data[m.token_offset+i*16+8:]))
+		if feature >= fsize {
+			return fmt.Errorf("feature offset of token %d is out of range", i)
+		}
+	}
+	if fsize > 0 && m.data[len(m.data)-1] != 0 {
+		return fmt.Errorf("feature is not terminated")
+	}
+	return nil
+}
 
-	return m, err
+func (m *mecabDic) close() error {
+	err := closeData(m.data, m.mapped)
+	m.data = nil
+	return err
 }
 
 func (m *mecabDic) baseCheck(idx uint32) (int32, uint32) {
-	i := m.da_offset + int(idx*8)
+	if idx >= m.da_size {
+		// out of the double array, never matches with a valid base
+		return 0, 0xFFFFFFFF
+	}
+	i := m.da_offset + int(idx)*8
 	base := int32(binary.LittleEndian.Uint32(m.data[i:]))
 	check := binary.LittleEndian.Uint32(m.data[i+4:])
 
@@ -299,6 +498,9 @@ func (m *mecabDic) commonPrefixSearch(s []byte) [][2]int32 {
 
 func (m *mecabDic) getEntriesByIndex(idx int, count int, s string, skip bool) []*DicEntry {
 	results := make([]*DicEntry, 0)
+	if idx < 0 || idx+count > m.lexsize {
+		return results
+	}
 	for i := 0; i < count; i++ {
 		d := new(DicEntry)
 		offset := m.token_offset + (idx+i)*16
@@ -310,6 +512,7 @@ func (m *mecabDic) getEntriesByIndex(idx int, count int, s string, skip bool) []
 		feature := int(binary.LittleEndian.Uint32(m.data[offset+8:]))
 		d.feature = c_str_to_string(m.data[m.feature_offset+feature:])
 		d.skip = skip
+		d.dic = m.name
 		results = append(results, d)
 	}
 
@@ -334,45 +537,82 @@ func (m *mecabDic) lookup(s []byte) []*DicEntry {
 	return results
 }
 
-func (m *mecabDic) lookupUnknowns(s []byte, cp *charProperty) ([]*DicEntry, bool) {
-	default_type, ln_list, invoke := cp.getUnknownLengths(s)
-	category_name := cp.category_names[int(default_type)]
-	result := m.exactMatchSearch([]byte(category_name))
+func (m *mecabDic) lookupInvalidByte(s []byte) []*DicEntry {
+	// an invalid UTF-8 byte is an unknown word of DEFAULT category
+	result := m.exactMatchSearch([]byte("DEFAULT"))
+	results := m.getEntries(int(result), string(s[:1]), false)
+	for _, e := range results {
+		e.stat = UnknownToken
+	}
+	return results
+}
+
+func (m *mecabDic) lookupUnknowns(s []byte, cp *charProperty, max_grouping_size int) ([]*DicEntry, bool) {
+	default_type, ln_list, invoke := cp.getUnknownLengths(s, max_grouping_size)
 	results := make([]*DicEntry, 0)
 	for _, ln := range ln_list {
-		newResults := m.getEntries(int(result), string(s[:ln]), category_name == "SPACE")
-		results = append(results, newResults...)
+		results = append(results, m.unknownEntries(s[:ln], default_type, cp)...)
 	}
 	return results, invoke
 }
 
+func (m *mecabDic) unknownEntries(s []byte, default_type uint32, cp *charProperty) []*DicEntry {
+	// unknown word entries of the category for s
+	category_name := cp.category_names[int(default_type)]
+	result := m.exactMatchSearch([]byte(category_name))
+	entries := m.getEntries(int(result), string(s), category_name == "SPACE")
+	for _, e := range entries {
+		e.stat = UnknownToken
+	}
+	return entries
+}
+
 // Matrix
 
 type matrix struct {
-	data  []byte
-	lsize int
-	rsize int
+	data   []byte
+	mapped bool
+	lsize  int
+	rsize  int
 }
 
-func newMatrix(path string) (m *matrix, err error) {
-	f, err := os.Open(path)
+func newMatrix(path string) (*matrix, error) {
+	return newMatrixWithOpener(path, openFile)
+}
 
-	if err != nil {
-		return nil, err
-	}
-	defer f.Close()
-	finfo, _ := f.Stat()
-	data, err := syscall.Mmap(int(f.Fd()), 0, int(finfo.Size()), syscall.PROT_READ, syscall.MAP_PRIVATE)
+func newMatrixWithOpener(path string, open fileOpener) (m *matrix, err error) {
+	data, mapped, err := open(path)
 	if err != nil {
 		return nil, err
 	}
 
 	m = new(matrix)
 	m.data = data
+	m.mapped = mapped
+	if err := m.parse(); err != nil {
+		m.close()
+		return nil, corruptError(path, err)
+	}
+
+	return m, err
+}
+
+func (m *matrix) parse() error {
+	if len(m.data) < 4 {
+		return fmt.Errorf("matrix.bin is too short (%d bytes)", len(m.data))
+	}
 	m.lsize = int(binary.LittleEndian.Uint16(m.data))
 	m.rsize = int(binary.LittleEndian.Uint16(m.data[2:]))
+	if len(m.data) != 4+m.lsize*m.rsize*2 {
+		return fmt.Errorf("invalid matrix.bin size %d for %d x %d", len(m.data), m.lsize, m.rsize)
+	}
+	return nil
+}
 
-	return m, err
+func (m *matrix) close() error {
+	err := closeData(m.data, m.mapped)
+	m.data = nil
+	return err
 }
 
 func (m *matrix) getTransCost(id1 int, id2 int) int32 {
@@ -25,6 +25,10 @@ SOFTWARE.
 package goawabi
 
 import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -43,7 +47,7 @@ func TestMatrix(t *testing.T) {
 	}
 }
 
-func assertGetCharInfo(t *testing.T, cp *charProperty, code_point uint16, default_type uint32, char_type uint32, char_count uint32, group uint32, invoke uint32) {
+func assertGetCharInfo(t *testing.T, cp *charProperty, code_point uint32, default_type uint32, char_type uint32, char_count uint32, group uint32, invoke uint32) {
 	v1, v2, v3, v4, v5 := cp.getCharInfo(code_point)
 	if v1 != default_type || v2 != char_type || v3 != char_count || v4 != group || v5 != invoke {
 		t.Errorf("cp.getCharInfo(%d) is failed", code_point)
@@ -127,7 +131,7 @@ func TestLookupUnknowns(t *testing.T) {
 		t.Errorf("exactMatchSearch() failed")
 	}
 
-	entries, invoke := unk_dic.lookupUnknowns([]byte("１９６７年"), cp)
+	entries, invoke := unk_dic.lookupUnknowns([]byte("１９６７年"), cp, MAX_GROUPING_SIZE)
 	if len(entries) != 1 || invoke != true {
 		t.Errorf("lookupUnknowns() failed")
 	}
@@ -135,3 +139,118 @@ func TestLookupUnknowns(t *testing.T) {
 		t.Errorf("lookupUnknowns() failed:%s", entries[0].original)
 	}
 }
+
+func TestCorruptDictionary(t *testing.T) {
+	dir := compileTestDictionary(t)
+	tmp := t.TempDir()
+	write := func(name string, data []byte) string {
+		path := filepath.Join(tmp, name)
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	sys_data, _ := os.ReadFile(filepath.Join(dir, "sys.dic"))
+	for _, ln := range []int{0, 10, 72, 100, len(sys_data) - 1} {
+		if _, err := newMecabDic(write("sys.dic", sys_data[:ln])); err == nil {
+			t.Errorf("truncated sys.dic (%d bytes) must be error", ln)
+		}
+	}
+	data := append([]byte{}, sys_data...)
+	binary.LittleEndian.PutUint32(data[4:], 101)
+	if _, err := newMecabDic(write("sys.dic", data)); err == nil || !strings.Contains(err.Error(), "version") {
+		t.Errorf("invalid version must be error:%v", err)
+	}
+	data = append([]byte{}, sys_data...)
+	token_offset := 72 + int(binary.LittleEndian.Uint32(data[24:]))
+	binary.LittleEndian.PutUint32(data[token_offset+8:], 0xFFFFFF)
+	if _, err := newMecabDic(write("sys.dic", data)); err == nil || !strings.Contains(err.Error(), "feature offset") {
+		t.Errorf("invalid feature offset must be error:%v", err)
+	}
+
+	matrix_data, _ := os.ReadFile(filepath.Join(dir, "matrix.bin"))
+	for _, ln := range []int{0, 3, len(matrix_data) - 2} {
+		if _, err := newMatrix(write("matrix.bin", matrix_data[:ln])); err == nil {
+			t.Errorf("truncated matrix.bin (%d bytes) must be error", ln)
+		}
+	}
+
+	char_data, _ := os.ReadFile(filepath.Join(dir, "char.bin"))
+	if _, err := newCharProperty(write("char.bin", char_data[:len(char_data)-4])); err == nil {
+		t.Errorf("truncated char.bin must be error")
+	}
+	data = append([]byte{}, char_data...)
+	binary.LittleEndian.PutUint32(data[len(data)-4:], 30<<18)
+	if _, err := newCharProperty(write("char.bin", data)); err == nil {
+		t.Errorf("invalid category in char.bin must be error")
+	}
+
+	// context ids out of range of matrix.bin are rejected on loading
+	data = append([]byte{}, sys_data...)
+	lexsize := int(binary.LittleEndian.Uint32(data[12:]))
+	for i := 0; i < lexsize; i++ {
+		binary.LittleEndian.PutUint16(data[token_offset+i*16:], 0x7fff)
+	}
+	os.WriteFile(filepath.Join(dir, "sys.dic"), data, 0644)
+	if _, err := NewTokenizer(writeTestMecabrc(t, dir, "")); err == nil || !strings.Contains(err.Error(), "context id out of range") {
+		t.Errorf("context id of sys.dic must be validated:%v", err)
+	}
+	os.WriteFile(filepath.Join(dir, "sys.dic"), sys_data, 0644)
+	tokenizer, err := NewTokenizer(writeTestMecabrc(t, dir, ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	user_dic_path := filepath.Join(tmp, "user.dic")
+	if err := CompileUserDictionary(dir, []string{filepath.Join("testdata", "userdic", "user.csv")}, user_dic_path); err != nil {
+		t.Fatal(err)
+	}
+	data, _ = os.ReadFile(user_dic_path)
+	binary.LittleEndian.PutUint16(data[72+int(binary.LittleEndian.Uint32(data[24:]))+2:], 0x7fff)
+	err = tokenizer.AddUserDictionary("corrupt", write("user.dic", data))
+	if err == nil || !strings.Contains(err.Error(), "context id out of range") {
+		t.Errorf("context id of user dictionary must be validated:%v", err)
+	}
+	tokenizer.Close()
+
+	// matrix.bin of different context size
+	binary.LittleEndian.PutUint16(matrix_data, 1)
+	binary.LittleEndian.PutUint16(matrix_data[2:], 1)
+	os.WriteFile(filepath.Join(dir, "matrix.bin"), matrix_data[:6], 0644)
+	_, err = NewTokenizer(writeTestMecabrc(t, dir, ""))
+	if err == nil || !strings.Contains(err.Error(), "doesn't match matrix.bin") {
+		t.Errorf("context size must be validated:%v", err)
+	}
+}
+
+func TestSupplementaryCharacter(t *testing.T) {
+	tokenizer := newTestTokenizer(t)
+	cp := tokenizer.cp
+
+	if ch, ln := utf8ToUcs4([]byte("𠮷"), 0); ch != 0x20BB7 || ln != 4 {
+		t.Errorf("utf8ToUcs4() failed:%x %d", ch, ln)
+	}
+	assertGetCharInfo(t, cp, 0x20BB7, 2, 4, 2, 0, 0)  // KANJI 𠮷
+	assertGetCharInfo(t, cp, 0x1F600, 3, 8, 0, 1, 1)  // SYMBOL 😀
+	assertGetCharInfo(t, cp, 0x10FFFF, 3, 8, 0, 1, 1) // SYMBOL
+
+	morphemes, err := tokenizer.Tokenize("😀😀は𠮷")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(morphemes) != 3 || morphemes[0][0] != "😀😀" || morphemes[2][0] != "𠮷" || morphemes[2][1] != "名詞,一般,*,*,*,*,*" {
+		t.Errorf("Tokenize() failed:%v", morphemes)
+	}
+
+	if err := tokenizer.SetCharCategory(0x1F600, 0x1F64F, "ALPHA"); err != nil {
+		t.Fatal(err)
+	}
+	assertGetCharInfo(t, cp, 0x1F600, 5, 32, 0, 1, 1) // ALPHA
+	assertGetCharInfo(t, cp, 0x1F650, 3, 8, 0, 1, 1)  // SYMBOL
+	if tokenizer.SetCharCategory(0x3042, 0x3042, "ALPHA") == nil {
+		t.Errorf("SetCharCategory() must fail for BMP")
+	}
+	if tokenizer.SetCharCategory(0x1F600, 0x1F64F, "EMOJI") == nil {
+		t.Errorf("SetCharCategory() must fail for undefined category")
+	}
+}
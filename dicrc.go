@@ -0,0 +1,122 @@
+/*****************************************************************************
+MIT License
+
+Copyright (c) 2020 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*****************************************************************************/
+
+package goawabi
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strconv"
+	"strings"
+)
+
+// dicrc
+
+const (
+	DEFAULT_COST_FACTOR = 700
+	DEFAULT_BOS_FEATURE = "BOS/EOS,*,*,*,*,*,*,*,*"
+)
+
+// DicConfig is the settings in dicrc of the dictionary directory.
+// EvalSize and UnkEvalSize are only informational, they are used by
+// mecab-cost-train and ignored by the tokenizer.
+type DicConfig struct {
+	CostFactor    int
+	BOSFeature    string
+	EvalSize      int
+	UnkEvalSize   int
+	ConfigCharset string // charset of the sources, empty if not specified
+	// all the entries including node-format-*, eos-format-* etc.
+	Values map[string]string
+}
+
+func newDicConfig() *DicConfig {
+	return &DicConfig{
+		CostFactor: DEFAULT_COST_FACTOR,
+		BOSFeature: DEFAULT_BOS_FEATURE,
+		Values:     make(map[string]string),
+	}
+}
+
+func parseDicrc(data []byte) (*DicConfig, error) {
+	values, err := parse_mecabrc(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	config := newDicConfig()
+	config.Values = values
+	for key, p := range map[string]*int{
+		"cost-factor":   &config.CostFactor,
+		"eval-size":     &config.EvalSize,
+		"unk-eval-size": &config.UnkEvalSize,
+	} {
+		val, ok := values[key]
+		if !ok {
+			continue
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(val))
+		if err != nil || n < 0 || (key == "cost-factor" && n == 0) {
+			return nil, fmt.Errorf("invalid %s: %s", key, val)
+		}
+		*p = n
+	}
+	if val, ok := values["bos-feature"]; ok {
+		config.BOSFeature = strings.TrimSpace(val)
+	}
+	config.ConfigCharset = strings.TrimSpace(values["config-charset"])
+	return config, nil
+}
+
+func isUTF8Charset(charset string) bool {
+	switch strings.ToLower(charset) {
+	case "utf-8", "utf8":
+		return true
+	}
+	return false
+}
+
+func loadDicrc(path string, open fileOpener) (*DicConfig, error) {
+	// dicrc is optional
+	data, mapped, err := open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return newDicConfig(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer closeData(data, mapped)
+	config, err := parseDicrc(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return config, nil
+}
+
+// format returns the format string of kind (node, unk, bos, eos) for name
+func (config *DicConfig) format(kind string, name string) (string, bool) {
+	val, ok := config.Values[kind+"-format-"+name]
+	return val, ok
+}
@@ -0,0 +1,80 @@
+/*****************************************************************************
+MIT License
+
+Copyright (c) 2020 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*****************************************************************************/
+
+package goawabi
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDicConfig(t *testing.T) {
+	tokenizer := newTestTokenizer(t)
+	config := tokenizer.Config()
+	if config.CostFactor != 800 || config.BOSFeature != "BOS/EOS,*,*,*,*,*,*,*,*" || config.EvalSize != 8 || config.UnkEvalSize != 4 || config.ConfigCharset != "UTF-8" {
+		t.Errorf("Config() failed:%v", config)
+	}
+	if config.Values["node-format-wakati"] != "%M " {
+		t.Errorf("Config() failed:%q", config.Values["node-format-wakati"])
+	}
+
+	if _, err := parseDicrc([]byte("cost-factor = abc\n")); err == nil {
+		t.Errorf("parseDicrc() must fail for invalid cost-factor")
+	}
+	config2, err := parseDicrc([]byte(""))
+	if err != nil || config2.CostFactor != DEFAULT_COST_FACTOR || config2.BOSFeature != DEFAULT_BOS_FEATURE {
+		t.Errorf("parseDicrc() failed:%v %v", config2, err)
+	}
+}
+
+func TestNonUTF8Dictionary(t *testing.T) {
+	dir := compileTestDictionary(t)
+
+	// sys.dic compiled into UTF-8 from EUC-JP sources
+	os.WriteFile(filepath.Join(dir, "dicrc"), []byte("config-charset = EUC-JP\n"), 0644)
+	tokenizer, err := NewTokenizer(writeTestMecabrc(t, dir, ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokenizer.Close()
+
+	path := filepath.Join(dir, "sys.dic")
+	data, _ := os.ReadFile(path)
+	copy(data[40:72], make([]byte, 32))
+	os.WriteFile(path, data, 0644)
+	_, err = NewTokenizer(writeTestMecabrc(t, dir, ""))
+	if err == nil || !strings.Contains(err.Error(), "charset EUC-JP is not supported") {
+		t.Errorf("config-charset must be used without charset of sys.dic:%v", err)
+	}
+
+	os.WriteFile(filepath.Join(dir, "dicrc"), []byte(""), 0644)
+	copy(data[40:72], "EUC-JP")
+	os.WriteFile(path, data, 0644)
+	_, err = NewTokenizer(writeTestMecabrc(t, dir, ""))
+	if err == nil || !strings.Contains(err.Error(), "charset EUC-JP is not supported") {
+		t.Errorf("sys.dic of EUC-JP must be rejected:%v", err)
+	}
+}
@@ -0,0 +1,301 @@
+/*****************************************************************************
+MIT License
+
+Copyright (c) 2020 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*****************************************************************************/
+
+package goawabi
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Dictionary
+
+// pathes of dictionary files
+type dicFiles struct {
+	sys_dic       string
+	unk_dic       string
+	matrix        string
+	char_property string
+	dicrc         string // optional
+	user_dics     []string
+}
+
+func newDicFiles(mecabrc_map map[string]string) *dicFiles {
+	files := &dicFiles{user_dics: get_userdic_pathes(mecabrc_map)}
+	if _, ok := mecabrc_map["dicdir"]; ok {
+		files.sys_dic = get_dic_path(mecabrc_map, "sys.dic")
+		files.unk_dic = get_dic_path(mecabrc_map, "unk.dic")
+		files.matrix = get_dic_path(mecabrc_map, "matrix.bin")
+		files.char_property = get_dic_path(mecabrc_map, "char.bin")
+		files.dicrc = get_dic_path(mecabrc_map, "dicrc")
+	}
+	return files
+}
+
+func (files *dicFiles) check() error {
+	for _, f := range []struct{ name, path string }{
+		{"sys.dic", files.sys_dic},
+		{"unk.dic", files.unk_dic},
+		{"matrix.bin", files.matrix},
+		{"char.bin", files.char_property},
+	} {
+		if f.path == "" {
+			return fmt.Errorf("dicdir is not specified, can't find %s", f.name)
+		}
+	}
+	return nil
+}
+
+// Dictionary is a system dictionary (sys.dic, unk.dic, matrix.bin, char.bin
+// and dicrc) opened once and shared by Tokenizers created with NewTokenizer().
+// It is immutable and safe for concurrent use. Files are unmapped when the
+// Dictionary and all the Tokenizers on it are closed.
+type Dictionary struct {
+	sys_dic   *mecabDic
+	unk_dic   *mecabDic
+	cp        *charProperty
+	m         *matrix
+	config    *DicConfig
+	user_dics []string // userdic of mecabrc and WithUserDic of OpenDictionary
+	open      fileOpener
+	stat      fileStater
+
+	mu     sync.Mutex
+	refs   int
+	closed bool
+}
+
+func validateDic(dic *mecabDic, dic_type int, m *matrix) error {
+	if dic.dic_type != dic_type {
+		return corruptError(dic.path, fmt.Errorf("invalid dictionary type %d", dic.dic_type))
+	}
+	if dic.lsize != m.lsize || dic.rsize != m.rsize {
+		return corruptError(dic.path, fmt.Errorf("context size (%d, %d) doesn't match matrix.bin (%d, %d)",
+			dic.lsize, dic.rsize, m.lsize, m.rsize))
+	}
+	// left id is a right side of the transition and vice versa
+	for i := 0; i < dic.lexsize; i++ {
+		offset := dic.token_offset + i*16
+		lc_attr := int(binary.LittleEndian.Uint16(dic.data[offset:]))
+		rc_attr := int(binary.LittleEndian.Uint16(dic.data[offset+2:]))
+		if lc_attr >= m.rsize || rc_attr >= m.lsize {
+			return corruptError(dic.path, fmt.Errorf("context id out of range (%d, %d) of token %d", lc_attr, rc_attr, i))
+		}
+	}
+	return nil
+}
+
+func openDictionary(files *dicFiles, open fileOpener, stat fileStater) (*Dictionary, error) {
+	if err := files.check(); err != nil {
+		return nil, err
+	}
+	d := &Dictionary{user_dics: files.user_dics, open: open, stat: stat}
+	if err := d.load(files); err != nil {
+		d.unmap()
+		return nil, err
+	}
+	return d, nil
+}
+
+func (d *Dictionary) load(files *dicFiles) error {
+	sys_dic, err := newMecabDicWithOpener(files.sys_dic, d.open)
+	if err != nil {
+		return err
+	}
+	sys_dic.name = SystemDictionary
+	d.sys_dic = sys_dic
+
+	cp, err := newCharPropertyWithOpener(files.char_property, d.open)
+	if err != nil {
+		return err
+	}
+	d.cp = cp
+
+	unk_dic, err := newMecabDicWithOpener(files.unk_dic, d.open)
+	if err != nil {
+		return err
+	}
+	unk_dic.name = UnknownDictionary
+	d.unk_dic = unk_dic
+
+	m, err := newMatrixWithOpener(files.matrix, d.open)
+	if err != nil {
+		return err
+	}
+	d.m = m
+
+	d.config = newDicConfig()
+	if files.dicrc != "" {
+		config, err := loadDicrc(files.dicrc, d.open)
+		if err != nil {
+			return err
+		}
+		d.config = config
+	}
+
+	return d.validate()
+}
+
+func (d *Dictionary) validate() error {
+	if err := validateDic(d.sys_dic, SYS_DIC, d.m); err != nil {
+		return err
+	}
+	// charset of sys.dic is the encoding of the compiled dictionary,
+	// config-charset of dicrc is used if it is not specified
+	charset := d.sys_dic.charset
+	if charset == "" {
+		charset = d.config.ConfigCharset
+	}
+	if charset != "" && !isUTF8Charset(charset) {
+		return fmt.Errorf("%s: charset %s is not supported, only UTF-8 dictionaries can be used", d.sys_dic.path, charset)
+	}
+	if err := validateDic(d.unk_dic, UNK_DIC, d.m); err != nil {
+		return err
+	}
+	for _, name := range d.cp.category_names {
+		if d.unk_dic.exactMatchSearch([]byte(name)) < 0 {
+			return corruptError(d.unk_dic.path, fmt.Errorf("category [%s] is undefined", name))
+		}
+	}
+	return nil
+}
+
+// OpenDictionary opens a system dictionary located by WithMecabrc, WithDicDir,
+// WithSysDic, WithUnkDic, WithMatrix and WithCharProperty.
+// User dictionaries of userdic in mecabrc and WithUserDic are loaded
+// by every Tokenizer on it. Other options are ignored.
+func OpenDictionary(opts ...Option) (*Dictionary, error) {
+	o, err := newOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	files, err := o.dicFiles()
+	if err != nil {
+		return nil, err
+	}
+	files.user_dics = append(files.user_dics, o.user_dics...)
+	return openDictionary(files, openFile, statFile)
+}
+
+// NewTokenizer creates a Tokenizer sharing d, with its own user dictionaries
+// and settings. Options to locate the system dictionary can't be used.
+// Paths of WithUserDic are in the same file system as d.
+func (d *Dictionary) NewTokenizer(opts ...Option) (*Tokenizer, error) {
+	o, err := newOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	if o.locatesDictionary() {
+		return nil, errors.New("options to locate dictionary can't be used for Dictionary.NewTokenizer()")
+	}
+	return d.newTokenizer(o, d.user_dics)
+}
+
+func (d *Dictionary) newTokenizer(o *options, user_dics []string) (*Tokenizer, error) {
+	if err := d.acquire(); err != nil {
+		return nil, err
+	}
+	tok := newTokenizer()
+	tok.dic = d
+	tok.sys_dic = d.sys_dic
+	tok.unk_dic = d.unk_dic
+	cp := *d.cp // SetCharCategory() doesn't affect other Tokenizers
+	tok.cp = &cp
+	tok.m = d.m
+	tok.config = d.config
+	tok.max_grouping_size = o.max_grouping_size
+	tok.theta = o.theta
+	tok.whitespace = o.whitespace
+	tok.invalid = o.invalid
+	tok.max_input_size = o.max_input_size
+	tok.max_nodes = o.max_nodes
+
+	for _, path := range append(append([]string{}, user_dics...), o.user_dics...) {
+		u, err := tok.loadUserDic(path, path, d.open, d.stat)
+		if err != nil {
+			tok.unmap()
+			return nil, err
+		}
+		tok.user_dics = append(tok.user_dics, u)
+	}
+	return tok, nil
+}
+
+func (d *Dictionary) acquire() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return errDictionaryClosed
+	}
+	d.refs++
+	return nil
+}
+
+func (d *Dictionary) release() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.refs--
+	if d.closed && d.refs == 0 {
+		d.unmap()
+	}
+}
+
+func (d *Dictionary) unmap() error {
+	closers := make([]interface{ close() error }, 0)
+	for _, dic := range []*mecabDic{d.sys_dic, d.unk_dic} {
+		if dic != nil {
+			closers = append(closers, dic)
+		}
+	}
+	if d.cp != nil {
+		closers = append(closers, d.cp)
+	}
+	if d.m != nil {
+		closers = append(closers, d.m)
+	}
+
+	var err error
+	for _, c := range closers {
+		if e := c.close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// Close closes d. Tokenizers on d are still available until they are closed.
+func (d *Dictionary) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return errDictionaryClosed
+	}
+	d.closed = true
+	if d.refs == 0 {
+		return d.unmap()
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+/*****************************************************************************
+MIT License
+
+Copyright (c) 2020 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*****************************************************************************/
+
+package goawabi
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestDictionary(t *testing.T) {
+	dir := compileTestDictionary(t)
+	user_dic_path := filepath.Join(dir, "user.dic")
+	if err := CompileUserDictionary(dir, []string{filepath.Join("testdata", "userdic", "user.csv")}, user_dic_path); err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := OpenDictionary(WithDicDir(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tok1, err := d.NewTokenizer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tok2, err := d.NewTokenizer(WithUserDic(user_dic_path), WithWhitespacePolicy(WhitespaceToken))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tok1.sys_dic != tok2.sys_dic || tok1.m != tok2.m {
+		t.Errorf("Dictionary must be shared")
+	}
+	if _, err := d.NewTokenizer(WithDicDir(dir)); err == nil {
+		t.Errorf("Dictionary.NewTokenizer() must fail with WithDicDir")
+	}
+
+	tokens1, err := tok1.TokenizeTokens("母はハハハ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens2, err := tok2.TokenizeTokens("母はハハハ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tokens1[2].Dictionary != UnknownDictionary || tokens2[2].Dictionary != user_dic_path {
+		t.Errorf("user dictionaries must not be shared:%v %v", tokens1, tokens2)
+	}
+
+	if err := tok1.SetCharCategory(0x1F600, 0x1F64F, "ALPHA"); err != nil {
+		t.Fatal(err)
+	}
+	if c, _, _, _, _ := tok2.cp.getCharInfo(0x1F600); tok2.cp.category_names[c] != "SYMBOL" {
+		t.Errorf("SetCharCategory() must not affect other Tokenizers")
+	}
+
+	// Tokenizers are available after the Dictionary is closed
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.NewTokenizer(); !errors.Is(err, ErrClosed) || err.Error() != "Dictionary is closed" {
+		t.Errorf("NewTokenizer() after Close() must be ErrClosed:%v", err)
+	}
+	if err := d.Close(); !errors.Is(err, ErrClosed) || err.Error() != "Dictionary is closed" {
+		t.Errorf("Close() twice must be ErrClosed:%v", err)
+	}
+	if _, err := tok1.Tokenize("すもも"); err != nil {
+		t.Error(err)
+	}
+	tok1.Close()
+	if d.sys_dic.data == nil {
+		t.Errorf("Dictionary must not be unmapped while tok2 is open")
+	}
+	tok2.Close()
+	if d.sys_dic.data != nil {
+		t.Errorf("Dictionary must be unmapped")
+	}
+}
+
+func TestConcurrentTokenize(t *testing.T) {
+	dir := compileTestDictionary(t)
+	d, err := OpenDictionary(WithDicDir(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	tok1, err := d.NewTokenizer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tok1.Close()
+	tok2, err := d.NewTokenizer(WithMaxGroupingSize(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tok2.Close()
+
+	inputs := []string{"すもももももももものうち", "母は笑う", "山嵐 と 1,000年", "アイウエオ😀"}
+	expected := make(map[string]string)
+	for _, tok := range []*Tokenizer{tok1, tok2} {
+		for _, s := range inputs {
+			tokens_list, err := tok.TokenizeNBestTokens(s, 3)
+			if err != nil {
+				t.Fatal(err)
+			}
+			expected[fmt.Sprintf("%p%s", tok, s)] = fmt.Sprint(tokens_list)
+		}
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 16)
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			tok := []*Tokenizer{tok1, tok2}[i%2]
+			for j := 0; j < 50; j++ {
+				s := inputs[(i+j)%len(inputs)]
+				tokens_list, err := tok.TokenizeNBestTokens(s, 3)
+				if err != nil {
+					errs <- err
+					return
+				}
+				if fmt.Sprint(tokens_list) != expected[fmt.Sprintf("%p%s", tok, s)] {
+					errs <- fmt.Errorf("TokenizeNBestTokens(%s) differs", s)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,56 @@
+/*****************************************************************************
+MIT License
+
+Copyright (c) 2020 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*****************************************************************************/
+
+package goawabi
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrClosed            = errors.New("closed") // Tokenizer or Dictionary is closed
+	ErrConstraint        = errors.New("constraints can't be satisfied")
+	ErrDictionaryCorrupt = errors.New("dictionary is corrupt")
+	ErrInvalidUTF8       = errors.New("invalid UTF-8 string")
+	ErrLatticeBroken     = errors.New("lattice is broken")
+	ErrLatticeLimit      = errors.New("lattice limit exceeded")
+)
+
+// ErrClosed of each type
+var (
+	errTokenizerClosed  = fmt.Errorf("Tokenizer is %w", ErrClosed)
+	errDictionaryClosed = fmt.Errorf("Dictionary is %w", ErrClosed)
+)
+
+func corruptError(path string, err error) error {
+	return fmt.Errorf("%s: %w: %v", path, ErrDictionaryCorrupt, err)
+}
+
+// recover boundary of public API, a bad input must not crash the process
+func recoverError(err *error) {
+	if r := recover(); r != nil {
+		*err = fmt.Errorf("%w: %v", ErrLatticeBroken, r)
+	}
+}
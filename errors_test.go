@@ -0,0 +1,71 @@
+/*****************************************************************************
+MIT License
+
+Copyright (c) 2020 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*****************************************************************************/
+
+package goawabi
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestErrors(t *testing.T) {
+	tokenizer := newTestTokenizer(t)
+
+	if _, err := tokenizer.Tokenize("すもも\xff"); !errors.Is(err, ErrInvalidUTF8) {
+		t.Errorf("Tokenize() must return ErrInvalidUTF8:%v", err)
+	}
+	if _, err := tokenizer.TokenizeNBest("\xe3\x81", 2); !errors.Is(err, ErrInvalidUTF8) {
+		t.Errorf("TokenizeNBest() must return ErrInvalidUTF8:%v", err)
+	}
+
+	if ch, ln := utf8ToUcs4([]byte("\xe3\x81"), 0); ch != 0xFFFD || ln != 1 {
+		t.Errorf("utf8ToUcs4() of truncated sequence:%x %d", ch, ln)
+	}
+
+	lat, _ := newLattice([]byte("abc"))
+	if _, err := lat.backwardAstar(1, tokenizer.m); !errors.Is(err, ErrLatticeBroken) {
+		t.Errorf("backwardAstar() must return ErrLatticeBroken:%v", err)
+	}
+	if _, err := newBackwardPath(newNode(new(DicEntry)), nil, tokenizer.m); !errors.Is(err, ErrLatticeBroken) {
+		t.Errorf("newBackwardPath() must return ErrLatticeBroken:%v", err)
+	}
+
+	err := func() (err error) {
+		defer recoverError(&err)
+		var nodes []*Node
+		_ = nodes[1]
+		return nil
+	}()
+	if !errors.Is(err, ErrLatticeBroken) {
+		t.Errorf("recoverError() failed:%v", err)
+	}
+
+	dir := compileTestDictionary(t)
+	os.WriteFile(filepath.Join(dir, "sys.dic"), []byte("broken"), 0644)
+	if _, err := NewTokenizer(writeTestMecabrc(t, dir, "")); !errors.Is(err, ErrDictionaryCorrupt) {
+		t.Errorf("NewTokenizer() must return ErrDictionaryCorrupt:%v", err)
+	}
+}
@@ -0,0 +1,257 @@
+/*****************************************************************************
+MIT License
+
+Copyright (c) 2020 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*****************************************************************************/
+
+package goawabi
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Output format of dicrc (node-format-*, unk-format-*, bos-format-*, eos-format-*)
+
+func unescapeFormatChar(c byte) byte {
+	switch c {
+	case '0':
+		return 0
+	case 'a':
+		return '\a'
+	case 'b':
+		return '\b'
+	case 't':
+		return '\t'
+	case 'n':
+		return '\n'
+	case 'v':
+		return '\v'
+	case 'f':
+		return '\f'
+	case 'r':
+		return '\r'
+	case 's':
+		return ' '
+	}
+	return c
+}
+
+func parseFieldList(format string, i int) ([]int, int, error) {
+	// "[0,1,2]" at format[i:], returns indexes and the position after ']'
+	if i >= len(format) || format[i] != '[' {
+		return nil, i, fmt.Errorf("'[' is expected in format: %s", format)
+	}
+	end := strings.IndexByte(format[i:], ']')
+	if end < 0 {
+		return nil, i, fmt.Errorf("']' is expected in format: %s", format)
+	}
+	indexes := make([]int, 0)
+	for _, s := range strings.Split(format[i+1:i+end], ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil || n < 0 {
+			return nil, i, fmt.Errorf("invalid field index in format: %s", format)
+		}
+		indexes = append(indexes, n)
+	}
+	return indexes, i + end + 1, nil
+}
+
+func writeFields(sb *strings.Builder, features []string, indexes []int, sep string) {
+	if len(indexes) == 1 {
+		if indexes[0] < len(features) {
+			sb.WriteString(features[indexes[0]])
+		}
+		return
+	}
+	// '*' fields are omitted like MeCab
+	first := true
+	for _, n := range indexes {
+		if n >= len(features) || features[n] == "*" {
+			continue
+		}
+		if !first {
+			sb.WriteString(sep)
+		}
+		sb.WriteString(features[n])
+		first = false
+	}
+}
+
+func formatToken(sb *strings.Builder, format string, s string, t *Token, prev_cost int) error {
+	for i := 0; i < len(format); i++ {
+		c := format[i]
+		if c == '\\' && i+1 < len(format) {
+			i++
+			sb.WriteByte(unescapeFormatChar(format[i]))
+			continue
+		}
+		if c != '%' {
+			sb.WriteByte(c)
+			continue
+		}
+		i++
+		if i >= len(format) {
+			return fmt.Errorf("unexpected end of format: %s", format)
+		}
+		switch format[i] {
+		case '%':
+			sb.WriteByte('%')
+		case 'S':
+			sb.WriteString(s)
+		case 'L':
+			sb.WriteString(strconv.Itoa(len(s)))
+		case 'm':
+			sb.WriteString(t.Surface)
+		case 'M':
+			sb.WriteString(s[t.SpaceStart:t.End])
+		case 'h':
+			sb.WriteString(strconv.Itoa(t.PosId))
+		case 'c':
+			sb.WriteString(strconv.Itoa(t.WordCost))
+		case 's':
+			sb.WriteString(strconv.Itoa(int(t.Status)))
+		case 'H':
+			sb.WriteString(t.Feature)
+		case 'f', 'F':
+			sep := ","
+			if format[i] == 'F' {
+				i++
+				if i < len(format) && format[i] == '\\' {
+					i++
+				}
+				if i >= len(format) {
+					return fmt.Errorf("separator is expected in format: %s", format)
+				}
+				sep = string(format[i])
+				if format[i-1] == '\\' {
+					sep = string(unescapeFormatChar(format[i]))
+				}
+			}
+			indexes, next, err := parseFieldList(format, i+1)
+			if err != nil {
+				return err
+			}
+			writeFields(sb, t.Features, indexes, sep)
+			i = next - 1
+		case 'p':
+			i++
+			if i >= len(format) {
+				return fmt.Errorf("unexpected end of format: %s", format)
+			}
+			switch format[i] {
+			case 'S':
+				sb.WriteString(s[t.SpaceStart:t.Start])
+			case 's':
+				sb.WriteString(strconv.Itoa(t.Start))
+			case 'e':
+				sb.WriteString(strconv.Itoa(t.End))
+			case 'l':
+				sb.WriteString(strconv.Itoa(len(t.Surface)))
+			case 'L':
+				sb.WriteString(strconv.Itoa(t.End - t.SpaceStart))
+			case 'w':
+				sb.WriteString(strconv.Itoa(t.WordCost))
+			case 'c':
+				sb.WriteString(strconv.Itoa(t.Cost))
+			case 'C':
+				sb.WriteString(strconv.Itoa(t.Cost - prev_cost - t.WordCost))
+			case 'n':
+				sb.WriteString(strconv.Itoa(t.Cost - prev_cost))
+			case 'P':
+				sb.WriteString(strconv.FormatFloat(t.Prob, 'f', 6, 64))
+			case 'h':
+				i++
+				if i < len(format) && format[i] == 'l' {
+					sb.WriteString(strconv.Itoa(t.LeftId))
+				} else if i < len(format) && format[i] == 'r' {
+					sb.WriteString(strconv.Itoa(t.RightId))
+				} else {
+					return fmt.Errorf("unknown format specifier %%ph in format: %s", format)
+				}
+			default:
+				return fmt.Errorf("unknown format specifier %%p%c in format: %s", format[i], format)
+			}
+		default:
+			return fmt.Errorf("unknown format specifier %%%c in format: %s", format[i], format)
+		}
+	}
+	return nil
+}
+
+// FormatTokens renders tokens of s with node-format-<name>, unk-format-<name>,
+// bos-format-<name> and eos-format-<name> of dicrc, like `mecab -O<name>`.
+// Offsets of tokens must be in s, otherwise an error is returned.
+func (tok *Tokenizer) FormatTokens(s string, tokens []Token, name string) (out string, err error) {
+	defer recoverError(&err)
+
+	for i, t := range tokens {
+		if t.SpaceStart < 0 || t.SpaceStart > t.Start || t.Start > t.End || t.End > len(s) {
+			return "", fmt.Errorf("token %d %q [%d, %d) is out of range of the string of %d bytes", i, t.Surface, t.Start, t.End, len(s))
+		}
+	}
+	node_format, ok := tok.config.format("node", name)
+	if !ok {
+		return "", fmt.Errorf("Can't find node-format-%s in dicrc", name)
+	}
+	unk_format, ok := tok.config.format("unk", name)
+	if !ok {
+		unk_format = node_format
+	}
+	bos_format, _ := tok.config.format("bos", name)
+	eos_format, ok := tok.config.format("eos", name)
+	if !ok {
+		eos_format = "EOS\n"
+	}
+
+	var sb strings.Builder
+	bos := Token{
+		Feature:  tok.config.BOSFeature,
+		Features: splitFeature(tok.config.BOSFeature),
+		Status:   BOSToken,
+	}
+	if err := formatToken(&sb, bos_format, s, &bos, 0); err != nil {
+		return "", err
+	}
+	prev_cost := 0
+	for i := range tokens {
+		format := node_format
+		if tokens[i].Status == UnknownToken {
+			format = unk_format
+		}
+		if err := formatToken(&sb, format, s, &tokens[i], prev_cost); err != nil {
+			return "", err
+		}
+		prev_cost = tokens[i].Cost
+	}
+	eos := bos
+	eos.Status = EOSToken
+	eos.Start, eos.End, eos.SpaceStart = len(s), len(s), 0
+	if len(tokens) > 0 {
+		eos.SpaceStart = tokens[len(tokens)-1].End
+	}
+	eos.Cost = prev_cost
+	if err := formatToken(&sb, eos_format, s, &eos, prev_cost); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,75 @@
+/*****************************************************************************
+MIT License
+
+Copyright (c) 2020 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*****************************************************************************/
+
+package goawabi
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFormatTokens(t *testing.T) {
+	tokenizer := newTestTokenizer(t)
+	s := "すもももももももものうち"
+	tokens, err := tokenizer.TokenizeTokens(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		name     string
+		expected string
+	}{
+		{"simple", "すもも\t名詞-一般\nも\t助詞-係助詞\nもも\t名詞-一般\nも\t助詞-係助詞\nもも\t名詞-一般\nの\t助詞-連体化\nうち\t名詞-非自立-副詞可能\nEOS\n"},
+		{"wakati", "すもも も もも も もも の うち \n"},
+		{"yomi", "スモモモモモモモモノウチ\n"},
+	} {
+		out, err := tokenizer.FormatTokens(s, tokens, c.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out != c.expected {
+			t.Errorf("FormatTokens(%s) failed:%q", c.name, out)
+		}
+	}
+	if _, err := tokenizer.FormatTokens(s, tokens, "chasen"); err == nil {
+		t.Errorf("FormatTokens() must fail for undefined format")
+	}
+	// tokens of a longer string
+	if _, err := tokenizer.FormatTokens("すもも", tokens, "wakati"); err == nil {
+		t.Errorf("FormatTokens() must fail for tokens out of range")
+	}
+
+	var sb strings.Builder
+	token := tokens[0]
+	if err := formatToken(&sb, `%m %ps %pe %ph %%\s%f[0]`, s, &token, 0); err == nil {
+		t.Errorf("formatToken() must fail for unknown specifier")
+	}
+	sb.Reset()
+	if err := formatToken(&sb, `%m,%ps,%pe,%phl,%phr,%c,%s,%F\t[0,2,1]`, s, &token, 0); err != nil {
+		t.Fatal(err)
+	}
+	if sb.String() != "すもも,0,9,1,1,3000,0,名詞\t一般" {
+		t.Errorf("formatToken() failed:%q", sb.String())
+	}
+}
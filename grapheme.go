@@ -0,0 +1,135 @@
+/*****************************************************************************
+MIT License
+
+Copyright (c) 2020 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*****************************************************************************/
+
+package goawabi
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// Extended grapheme cluster (simplified UAX #29) for unknown word grouping
+
+const ZWJ = 0x200D
+
+func isGraphemeExtend(r rune) bool {
+	return unicode.In(r, unicode.Mn, unicode.Me, unicode.Mc) ||
+		r == ZWJ ||
+		(0xFE00 <= r && r <= 0xFE0F) || // variation selectors
+		(0xE0100 <= r && r <= 0xE01EF) || // ideographic variation selectors
+		(0x1F3FB <= r && r <= 0x1F3FF) || // emoji skin tone modifiers
+		(0xE0020 <= r && r <= 0xE007F) // tags
+}
+
+func isRegionalIndicator(r rune) bool {
+	return 0x1F1E6 <= r && r <= 0x1F1FF
+}
+
+func isPictographic(r rune) bool {
+	return r == 0x00A9 || r == 0x00AE || r == 0x203C || r == 0x2049 ||
+		r == 0x2122 || r == 0x2139 || r == 0x24C2 || r == 0x3030 ||
+		r == 0x303D || r == 0x3297 || r == 0x3299 ||
+		(0x2194 <= r && r <= 0x21AA) ||
+		(0x231A <= r && r <= 0x23FF) ||
+		(0x25AA <= r && r <= 0x25FE) ||
+		(0x2600 <= r && r <= 0x27BF) ||
+		(0x2934 <= r && r <= 0x2935) ||
+		(0x2B05 <= r && r <= 0x2B55) ||
+		(0x1F000 <= r && r <= 0x1FAFF && !isRegionalIndicator(r) && !(0x1F3FB <= r && r <= 0x1F3FF))
+}
+
+func isGraphemeBoundary(s []byte, k int) bool {
+	// s[0] must be a start of grapheme cluster
+	if k <= 0 || k >= len(s) {
+		return true
+	}
+	r, ln := utf8.DecodeRune(s[k:])
+	prev, _ := utf8.DecodeLastRune(s[:k])
+	if (r == utf8.RuneError && ln == 1) || prev == utf8.RuneError {
+		return true
+	}
+	if prev == '\r' && r == '\n' {
+		return false
+	}
+	if isGraphemeExtend(r) {
+		return false
+	}
+	if prev == ZWJ && isPictographic(r) {
+		return false
+	}
+	if isRegionalIndicator(prev) && isRegionalIndicator(r) {
+		// pair of regional indicators is a flag
+		n := 0
+		for i := k; i > 0; {
+			p, ln := utf8.DecodeLastRune(s[:i])
+			if !isRegionalIndicator(p) {
+				break
+			}
+			n++
+			i -= ln
+		}
+		return n%2 == 0
+	}
+	return true
+}
+
+func graphemeLength(s []byte) int {
+	// bytes length of the first grapheme cluster
+	if len(s) == 0 {
+		return 0
+	}
+	_, i := utf8.DecodeRune(s)
+	for i < len(s) && !isGraphemeBoundary(s, i) {
+		_, ln := utf8.DecodeRune(s[i:])
+		i += ln
+	}
+	return i
+}
+
+func alignToGrapheme(entries []*DicEntry, s []byte) []*DicEntry {
+	// an entry which ends inside a grapheme cluster absorbs the following
+	// extend characters (e.g. variation selectors), or it is dropped
+	aligned := entries[:0]
+	for _, entry := range entries {
+		k := len(entry.original)
+		for k >= 0 && !isGraphemeBoundary(s, k) {
+			r, ln := utf8.DecodeRune(s[k:])
+			if isGraphemeExtend(r) {
+				k += ln
+			} else {
+				k = -1
+			}
+		}
+		if k < 0 {
+			continue
+		}
+		if k != len(entry.original) {
+			e := *entry
+			e.original = string(s[:k])
+			entry = &e
+		}
+		aligned = append(aligned, entry)
+	}
+	return aligned
+}
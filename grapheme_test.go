@@ -0,0 +1,83 @@
+/*****************************************************************************
+MIT License
+
+Copyright (c) 2020 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*****************************************************************************/
+
+package goawabi
+
+import (
+	"testing"
+)
+
+func TestGraphemeLength(t *testing.T) {
+	for _, c := range []struct {
+		s  string
+		ln int
+	}{
+		{"a", 1},
+		{"\r\n", 2},
+		{"か\u3099き", 6},     // combining dakuten
+		{"葛\U000E0100と", 7}, // ideographic variation sequence
+		{"👍\U0001F3FD👍", 8}, // skin tone modifier
+		{"👨‍👩‍👧は", 18},      // ZWJ sequence
+		{"🇯🇵🇺🇸", 8},         // flags
+		{"\u3099あ", 3},
+	} {
+		if ln := graphemeLength([]byte(c.s)); ln != c.ln {
+			t.Errorf("graphemeLength(%q)=%d want %d", c.s, ln, c.ln)
+		}
+	}
+}
+
+func TestGraphemeTokenize(t *testing.T) {
+	tokenizer := newTestTokenizer(t)
+	for _, c := range []struct {
+		s        string
+		surfaces []string
+		statuses []TokenStatus
+		readings []string // empty if not checked
+	}{
+		{"葛\U000E0100は葛", []string{"葛\U000E0100", "は", "葛"}, []TokenStatus{NormalToken, NormalToken, NormalToken}, []string{"クズ", "ハ", "クズ"}},
+		{"👨‍👩‍👧は", []string{"👨‍👩‍👧", "は"}, []TokenStatus{UnknownToken, NormalToken}, []string{"", "ハ"}},
+		{"母\u0301と", []string{"母\u0301", "と"}, []TokenStatus{NormalToken, NormalToken}, []string{"ハハ", "ト"}},
+	} {
+		tokens, err := tokenizer.TokenizeTokens(c.s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(tokens) != len(c.surfaces) {
+			t.Errorf("TokenizeTokens(%q) failed:%v", c.s, tokens)
+			continue
+		}
+		for i, token := range tokens {
+			if token.Surface != c.surfaces[i] {
+				t.Errorf("TokenizeTokens(%q)[%d]=%q want %q", c.s, i, token.Surface, c.surfaces[i])
+			}
+			if token.Status != c.statuses[i] {
+				t.Errorf("TokenizeTokens(%q)[%d] status=%d want %d", c.s, i, token.Status, c.statuses[i])
+			}
+			if c.readings[i] != "" && (len(token.Features) < 8 || token.Features[7] != c.readings[i]) {
+				t.Errorf("TokenizeTokens(%q)[%d] features=%v want reading %s", c.s, i, token.Features, c.readings[i])
+			}
+		}
+	}
+}
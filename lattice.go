@@ -27,6 +27,7 @@ package goawabi
 import (
 	"container/heap"
 	"fmt"
+	"unicode/utf8"
 )
 
 // Node
@@ -43,7 +44,14 @@ type Node struct {
 	min_cost   int32
 	back_pos   int32
 	back_index int32
+	posid      int32
+	stat       TokenStatus
 	skip       bool
+	dic        string
+	lat        *Lattice
+	alpha      float64 // forward log score from BOS
+	beta       float64 // backward log score from EOS
+	prob       float64 // marginal probability
 }
 
 func newBos() *Node {
@@ -59,6 +67,7 @@ func newBos() *Node {
 	node.min_cost = 0
 	node.back_pos = -1
 	node.back_index = -1
+	node.stat = BOSToken
 	node.skip = false
 
 	return node
@@ -77,6 +86,7 @@ func newEos(pos int32) *Node {
 	node.min_cost = 0x7FFFFFFF
 	node.back_pos = -1
 	node.back_index = -1
+	node.stat = EOSToken
 	node.skip = false
 
 	return node
@@ -88,14 +98,17 @@ func newNode(e *DicEntry) *Node {
 	node.feature = e.feature
 	node.pos = 0
 	node.epos = 0
-	node.index = int32(e.posid)
+	node.index = 0
 	node.left_id = int32(e.lc_attr)
 	node.right_id = int32(e.rc_attr)
 	node.cost = int32(e.wcost)
 	node.min_cost = 0x7FFFFFFF
 	node.back_pos = -1
 	node.back_index = -1
+	node.posid = int32(e.posid)
+	node.stat = e.stat
 	node.skip = e.skip
+	node.dic = e.dic
 
 	return node
 }
@@ -125,13 +138,36 @@ func reverseNodes(nodes []*Node) {
 // Lattice
 
 type Lattice struct {
-	snodes [][]*Node
-	enodes [][]*Node
-	p      int32
+	snodes   [][]*Node
+	enodes   [][]*Node
+	rune_pos []int32 // lattice position to rune offset
+	byte_pos []int32 // lattice position to byte offset of the input, nil if same
+	p        int32
+	// left nodes of p, skipped whitespace is passed through
+	left_nodes []*Node
+	// feature of BOS and EOS
+	bos_feature string
+	node_count  int
+	tok         *Tokenizer // set by Tokenizer.BuildLattice()
+	log_z       float64    // partition function by forwardBackward()
 }
 
-func newLattice(size int) (lat *Lattice, err error) {
+func newLattice(s []byte) (lat *Lattice, err error) {
+	size := len(s)
 	lat = new(Lattice)
+	lat.rune_pos = make([]int32, size+3)
+	var n int32
+	for i := 0; i < size; {
+		// an invalid byte is counted as a rune
+		_, ln := utf8.DecodeRune(s[i:])
+		for j := 0; j < ln; j++ {
+			lat.rune_pos[i+j+1] = n
+		}
+		n++
+		i += ln
+	}
+	lat.rune_pos[size+1] = n
+	lat.rune_pos[size+2] = n
 	lat.snodes = make([][]*Node, size+2)
 	for i := 0; i < len(lat.snodes); i++ {
 		lat.snodes[i] = make([]*Node, 0)
@@ -142,33 +178,48 @@ func newLattice(size int) (lat *Lattice, err error) {
 	}
 
 	bos := newBos()
+	bos.lat = lat
 	lat.snodes[0] = append(lat.snodes[0], bos)
 	lat.enodes[1] = append(lat.enodes[1], bos)
 	lat.p = 1
+	lat.left_nodes = lat.enodes[1]
 
 	return lat, err
 }
 
+func (lat *Lattice) leftNodes(pos int32) []*Node {
+	// nodes ending at pos, chains of skipped whitespace are passed through
+	nodes := make([]*Node, 0, len(lat.enodes[pos]))
+	var visited map[int32]bool
+	positions := []int32{pos}
+	for len(positions) > 0 {
+		p := positions[len(positions)-1]
+		positions = positions[:len(positions)-1]
+		for _, enode := range lat.enodes[p] {
+			if !enode.skip {
+				nodes = append(nodes, enode)
+			} else if !visited[enode.pos] {
+				if visited == nil {
+					visited = make(map[int32]bool)
+				}
+				visited[enode.pos] = true
+				positions = append(positions, enode.pos)
+			}
+		}
+	}
+	return nodes
+}
+
 func (lat *Lattice) add(node *Node, m *matrix) {
+	// lat.left_nodes are left nodes of lat.p, computed once in forward()
 	min_cost := node.min_cost
-	best_node := lat.enodes[lat.p][0]
-
-	for _, enode := range lat.enodes[lat.p] {
-		if enode.skip {
-			for _, enode2 := range lat.enodes[enode.pos] {
-				cost := enode2.min_cost + m.getTransCost(int(enode2.right_id), int(node.left_id))
-				if cost < min_cost {
-					min_cost = cost
-					best_node = enode2
-				}
+	best_node := lat.left_nodes[0]
 
-			}
-		} else {
-			cost := enode.min_cost + m.getTransCost(int(enode.right_id), int(node.left_id))
-			if cost < min_cost {
-				min_cost = cost
-				best_node = enode
-			}
+	for _, enode := range lat.left_nodes {
+		cost := enode.min_cost + m.getTransCost(int(enode.right_id), int(node.left_id))
+		if cost < min_cost {
+			min_cost = cost
+			best_node = enode
 		}
 	}
 
@@ -179,34 +230,65 @@ func (lat *Lattice) add(node *Node, m *matrix) {
 	node.epos = lat.p + node.nodeLen()
 
 	node.index = int32(len(lat.snodes[lat.p]))
+	node.lat = lat
 
 	node_pos := node.pos
 	node_epos := node.epos
 	lat.snodes[node_pos] = append(lat.snodes[node_pos], node)
 	lat.enodes[node_epos] = append(lat.enodes[node_epos], node)
+	lat.node_count++
 }
 
-func (lat *Lattice) forward() int {
+func (lat *Lattice) forward() (int, error) {
 	old_p := lat.p
 	lat.p += 1
-	for len(lat.enodes[lat.p]) == 0 {
+	for int(lat.p) < len(lat.enodes) && len(lat.enodes[lat.p]) == 0 {
 		lat.p += 1
 	}
-	return int(lat.p - old_p)
+	if int(lat.p) >= len(lat.enodes) {
+		return 0, fmt.Errorf("%w: no node at position %d", ErrLatticeBroken, old_p-1)
+	}
+	lat.left_nodes = lat.leftNodes(lat.p)
+	return int(lat.p - old_p), nil
+}
+
+func (lat *Lattice) setBosFeature(feature string) {
+	lat.bos_feature = feature
+	lat.snodes[0][0].feature = feature
 }
 
 func (lat *Lattice) end(m *matrix) {
-	lat.add(newEos(lat.p), m)
+	eos := newEos(lat.p)
+	eos.feature = lat.bos_feature
+	lat.add(eos, m)
 	lat.snodes = lat.snodes[:lat.p+1]
 	lat.enodes = lat.enodes[:lat.p+2]
+	lat.rune_pos = lat.rune_pos[:lat.p+2]
+	if lat.byte_pos != nil {
+		lat.byte_pos = lat.byte_pos[:lat.p+2]
+	}
 }
 
-func (lat *Lattice) backward() []*Node {
+func (lat *Lattice) runeOffset(pos int32) int {
+	return int(lat.rune_pos[pos])
+}
+
+func (lat *Lattice) byteOffset(pos int32) int {
+	if lat.byte_pos != nil {
+		return int(lat.byte_pos[pos])
+	}
+	return int(pos) - 1
+}
+
+func (lat *Lattice) backward() ([]*Node, error) {
 	shortest_path := make([]*Node, 0)
 
 	pos := int32(len(lat.snodes)) - 1
 	var index int32
 	for pos >= 0 {
+		if int(pos) >= len(lat.snodes) || int(index) >= len(lat.snodes[pos]) || index < 0 {
+			return nil, fmt.Errorf("%w: invalid back pointer (%d, %d)", ErrLatticeBroken, pos, index)
+		}
 		node := lat.snodes[pos][index]
 		index = node.back_index
 		pos = node.back_pos
@@ -214,7 +296,7 @@ func (lat *Lattice) backward() []*Node {
 	}
 
 	reverseNodes(shortest_path)
-	return shortest_path
+	return shortest_path, nil
 }
 
 // Priority queue and N best results
@@ -245,17 +327,20 @@ func (h *backwardPathHeap) Pop() interface{} {
 	return x
 }
 
-func (lat *Lattice) backwardAstar(n int, m *matrix) [][]*Node {
+func (lat *Lattice) backwardAstar(n int, m *matrix) ([][]*Node, error) {
 	pathes := make([][]*Node, 0)
 	epos := len(lat.enodes) - 1
-	node := lat.enodes[epos][0]
-	if !node.isEos() {
-		panic("backwardAstar(): Invalid lattice")
+	if len(lat.enodes[epos]) == 0 || !lat.enodes[epos][0].isEos() {
+		return nil, fmt.Errorf("%w: backwardAstar(): no EOS", ErrLatticeBroken)
 	}
+	node := lat.enodes[epos][0]
 
 	pq := &backwardPathHeap{}
 	heap.Init(pq)
-	bp, _ := newBackwardPath(node, nil, m)
+	bp, err := newBackwardPath(node, nil, m)
+	if err != nil {
+		return nil, err
+	}
 	heap.Push(pq, bp)
 
 	for pq.Len() > 0 && n > 0 {
@@ -269,14 +354,17 @@ func (lat *Lattice) backwardAstar(n int, m *matrix) [][]*Node {
 		} else {
 			new_node := bp.back_path[len(bp.back_path)-1]
 			epos := new_node.epos - new_node.nodeLen()
-			for _, node := range lat.enodes[epos] {
-				bp, _ := newBackwardPath(node, bp, m)
+			for _, node := range lat.leftNodes(epos) {
+				bp, err := newBackwardPath(node, bp, m)
+				if err != nil {
+					return nil, err
+				}
 				heap.Push(pq, bp)
 			}
 		}
 	}
 
-	return pathes
+	return pathes, nil
 }
 
 // backward path for N-best A*
@@ -301,7 +389,7 @@ func newBackwardPath(node *Node, right_path *backwardPath, m *matrix) (bp *backw
 		}
 	} else {
 		if !node.isEos() {
-			panic("newBackwardPath(): Invalid lattice")
+			return nil, fmt.Errorf("%w: newBackwardPath(): path must start from EOS", ErrLatticeBroken)
 		}
 	}
 
@@ -331,3 +419,215 @@ func (bp *backwardPath) totalCost() int32 {
 func (bp *backwardPath) isComplete() bool {
 	return bp.back_path[len(bp.back_path)-1].isBos()
 }
+
+// Public API to inspect a lattice
+
+func (node *Node) Surface() string {
+	return node.original
+}
+
+func (node *Node) Feature() string {
+	return node.feature
+}
+
+func (node *Node) Features() []string {
+	return splitFeature(node.feature)
+}
+
+// Start returns byte offset of the node in the input, 0 for BOS.
+func (node *Node) Start() int {
+	if node.isBos() {
+		return 0
+	}
+	return node.lat.byteOffset(node.pos)
+}
+
+// End returns byte offset of the end of the node in the input.
+func (node *Node) End() int {
+	if node.isBos() || node.isEos() {
+		return node.Start()
+	}
+	return node.lat.byteOffset(node.epos)
+}
+
+func (node *Node) LeftId() int {
+	return int(node.left_id)
+}
+
+func (node *Node) RightId() int {
+	return int(node.right_id)
+}
+
+func (node *Node) PosId() int {
+	return int(node.posid)
+}
+
+func (node *Node) WordCost() int {
+	return int(node.cost)
+}
+
+// MinCost returns the cost of the best path from BOS to the node.
+func (node *Node) MinCost() int {
+	return int(node.min_cost)
+}
+
+func (node *Node) Status() TokenStatus {
+	return node.stat
+}
+
+func (node *Node) Dictionary() string {
+	return node.dic
+}
+
+func (node *Node) IsBOS() bool {
+	return node.isBos()
+}
+
+func (node *Node) IsEOS() bool {
+	return node.isEos()
+}
+
+// IsSkipped reports whether the node is a whitespace skipped by WhitespaceSkip.
+func (node *Node) IsSkipped() bool {
+	return node.skip
+}
+
+// BestPrev returns the previous node on the best path to the node, nil for BOS.
+func (node *Node) BestPrev() *Node {
+	if node.back_pos < 0 {
+		return nil
+	}
+	return node.lat.snodes[node.back_pos][node.back_index]
+}
+
+func (lat *Lattice) BOS() *Node {
+	return lat.snodes[0][0]
+}
+
+func (lat *Lattice) EOS() *Node {
+	return lat.snodes[lat.p][0]
+}
+
+func (lat *Lattice) positions(offset int) []int32 {
+	// lattice positions of byte offset in the input
+	if lat.byte_pos == nil {
+		if offset < 0 || int32(offset)+1 > lat.p {
+			return nil
+		}
+		return []int32{int32(offset) + 1}
+	}
+	positions := make([]int32, 0, 1)
+	for pos := int32(1); pos <= lat.p; pos++ {
+		if lat.byteOffset(pos) == offset {
+			positions = append(positions, pos)
+		}
+	}
+	return positions
+}
+
+// NodesStartingAt returns nodes starting at byte offset of the input,
+// EOS starts at the end of the input.
+func (lat *Lattice) NodesStartingAt(offset int) []*Node {
+	nodes := make([]*Node, 0)
+	for _, pos := range lat.positions(offset) {
+		nodes = append(nodes, lat.snodes[pos]...)
+	}
+	return nodes
+}
+
+// NodesEndingAt returns nodes ending at byte offset of the input,
+// BOS ends at 0.
+func (lat *Lattice) NodesEndingAt(offset int) []*Node {
+	nodes := make([]*Node, 0)
+	for _, pos := range lat.positions(offset) {
+		nodes = append(nodes, lat.enodes[pos]...)
+	}
+	return nodes
+}
+
+// Nodes returns all the nodes including BOS and EOS in order of start position.
+func (lat *Lattice) Nodes() []*Node {
+	nodes := make([]*Node, 0, lat.node_count+1)
+	for _, snodes := range lat.snodes {
+		nodes = append(nodes, snodes...)
+	}
+	return nodes
+}
+
+// Viterbi returns tokens of the best path.
+func (lat *Lattice) Viterbi() (tokens []Token, err error) {
+	if err := lat.tok.acquire(); err != nil {
+		return nil, err
+	}
+	defer lat.tok.release()
+	defer recoverError(&err)
+	return lat.viterbi(lat.tok.m)
+}
+
+func (lat *Lattice) viterbi(m *matrix) ([]Token, error) {
+	nodes, err := lat.backward()
+	if err != nil {
+		return nil, err
+	}
+	return nodesToTokens(lat, nodes, m), nil
+}
+
+// All returns tokens of all the nodes except BOS, EOS and skipped whitespaces
+// in order of start position (like mecab -a). Token.Cost is the cost of
+// the best path from BOS to the node, and Token.Best reports whether
+// the node is on the best path.
+func (lat *Lattice) All() (tokens []Token, err error) {
+	if err := lat.tok.acquire(); err != nil {
+		return nil, err
+	}
+	defer lat.tok.release()
+	defer recoverError(&err)
+	return lat.all()
+}
+
+func (lat *Lattice) all() ([]Token, error) {
+	best_nodes, err := lat.backward()
+	if err != nil {
+		return nil, err
+	}
+	best := make(map[*Node]bool, len(best_nodes))
+	for _, node := range best_nodes {
+		best[node] = true
+	}
+	tokens := make([]Token, 0, lat.node_count)
+	for _, snodes := range lat.snodes {
+		for _, node := range snodes {
+			if node.isBos() || node.isEos() || node.skip {
+				continue
+			}
+			tok := newToken(lat, node, node.BestPrev())
+			tok.Cost = int(node.min_cost)
+			tok.Prob = node.prob
+			tok.Best = best[node]
+			tokens = append(tokens, tok)
+		}
+	}
+	return tokens, nil
+}
+
+// NBest returns tokens of the n best pathes.
+func (lat *Lattice) NBest(n int) (tokens_list [][]Token, err error) {
+	if err := lat.tok.acquire(); err != nil {
+		return nil, err
+	}
+	defer lat.tok.release()
+	defer recoverError(&err)
+	return lat.nbest(n, lat.tok.m)
+}
+
+func (lat *Lattice) nbest(n int, m *matrix) ([][]Token, error) {
+	nodes_list, err := lat.backwardAstar(n, m)
+	if err != nil {
+		return nil, err
+	}
+	tokens_list := make([][]Token, 0, len(nodes_list))
+	for _, nodes := range nodes_list {
+		tokens_list = append(tokens_list, nodesToTokens(lat, nodes, m))
+	}
+	return tokens_list, nil
+}
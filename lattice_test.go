@@ -0,0 +1,214 @@
+/*****************************************************************************
+MIT License
+
+Copyright (c) 2020 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*****************************************************************************/
+
+package goawabi
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestBuildLattice(t *testing.T) {
+	tokenizer := newTestTokenizer(t)
+	s := "すもももももももものうち"
+	lat, err := tokenizer.BuildLattice(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !lat.BOS().IsBOS() || lat.BOS().End() != 0 || lat.BOS().BestPrev() != nil {
+		t.Errorf("BOS() failed")
+	}
+	if !lat.EOS().IsEOS() || lat.EOS().Start() != len(s) || lat.EOS().Feature() != "BOS/EOS,*,*,*,*,*,*,*,*" {
+		t.Errorf("EOS() failed")
+	}
+	surfaces := make(map[string]bool)
+	for _, node := range lat.NodesStartingAt(0) {
+		if node.Start() != 0 || node.End() != len(node.Surface()) {
+			t.Errorf("node offset failed:%s %d %d", node.Surface(), node.Start(), node.End())
+		}
+		surfaces[node.Surface()] = true
+	}
+	if !surfaces["すもも"] {
+		t.Errorf("NodesStartingAt(0) failed:%v", surfaces)
+	}
+	found := false
+	for _, node := range lat.NodesEndingAt(9) {
+		if node.Surface() == "すもも" {
+			found = node.Features()[0] == "名詞" && node.LeftId() == 1 && node.RightId() == 1 &&
+				node.WordCost() == 3000 && node.Dictionary() == SystemDictionary && node.Status() == NormalToken
+		}
+	}
+	if !found {
+		t.Errorf("NodesEndingAt(9) failed")
+	}
+	if nodes := lat.NodesEndingAt(0); len(nodes) != 1 || !nodes[0].IsBOS() {
+		t.Errorf("NodesEndingAt(0) failed:%v", nodes)
+	}
+	if nodes := lat.NodesStartingAt(len(s) + 1); len(nodes) != 0 {
+		t.Errorf("NodesStartingAt() out of range failed:%v", nodes)
+	}
+	if nodes := lat.Nodes(); len(nodes) < 10 || !nodes[0].IsBOS() || !nodes[len(nodes)-1].IsEOS() {
+		t.Errorf("Nodes() failed")
+	}
+
+	tokens, err := lat.Viterbi()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, _ := tokenizer.TokenizeTokens(s)
+	if fmt.Sprint(tokens) != fmt.Sprint(expected) {
+		t.Errorf("Viterbi() failed:%v", tokens)
+	}
+	// best path by BestPrev()
+	i := len(tokens) - 1
+	for node := lat.EOS().BestPrev(); !node.IsBOS(); node = node.BestPrev() {
+		if node.Surface() != tokens[i].Surface || node.Start() != tokens[i].Start || node.MinCost() != tokens[i].Cost {
+			t.Errorf("BestPrev() failed:%s", node.Surface())
+		}
+		i--
+	}
+	if i != -1 {
+		t.Errorf("BestPrev() failed:%d", i)
+	}
+	tokens_list, err := lat.NBest(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected_list, _ := tokenizer.TokenizeNBestTokens(s, 3)
+	if fmt.Sprint(tokens_list) != fmt.Sprint(expected_list) {
+		t.Errorf("NBest() failed:%v", tokens_list)
+	}
+
+	// offsets are of the input with InvalidUTF8Replace
+	tokenizer.SetInvalidUTF8Policy(InvalidUTF8Replace)
+	lat, err = tokenizer.BuildLattice("\xffもも")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ends := make(map[string]int)
+	for _, node := range lat.NodesStartingAt(1) {
+		if node.Start() != 1 {
+			t.Errorf("NodesStartingAt(1) failed:%s %d", node.Surface(), node.Start())
+		}
+		ends[node.Surface()] = node.End()
+	}
+	if len(ends) != 2 || ends["も"] != 4 || ends["もも"] != 7 {
+		t.Errorf("NodesStartingAt(1) end offsets failed:%v", ends)
+	}
+	if lat.EOS().Start() != 7 {
+		t.Errorf("EOS().Start() failed:%d", lat.EOS().Start())
+	}
+
+	tokenizer.Close()
+	if _, err := lat.Viterbi(); !errors.Is(err, ErrClosed) {
+		t.Errorf("Viterbi() after Close() must be ErrClosed:%v", err)
+	}
+}
+
+func TestTokenizeAll(t *testing.T) {
+	tokenizer := newTestTokenizer(t)
+	s := "すもももももももものうち"
+	tokens, err := tokenizer.TokenizeAll(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lat, _ := tokenizer.BuildLattice(s)
+	if nodes := lat.Nodes(); len(tokens) != len(nodes)-2 {
+		t.Errorf("TokenizeAll() must return all the nodes:%d %d", len(tokens), len(nodes))
+	}
+	expected, _ := tokenizer.TokenizeTokens(s)
+	best := make([]Token, 0)
+	for i, token := range tokens {
+		if i > 0 && token.Start < tokens[i-1].Start {
+			t.Errorf("TokenizeAll() must be in order of start:%v", token)
+		}
+		if token.Best {
+			token.Best = false
+			best = append(best, token)
+		}
+	}
+	if fmt.Sprint(best) != fmt.Sprint(expected) {
+		t.Errorf("TokenizeAll() best path failed:%v", best)
+	}
+
+	// skipped whitespaces are not returned
+	tokens, err = tokenizer.TokenizeAll("すもも もも")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, token := range tokens {
+		if token.Surface == " " {
+			t.Errorf("TokenizeAll() must not return skipped whitespace")
+		}
+	}
+	tokenizer.SetWhitespacePolicy(WhitespaceToken)
+	tokens, _ = tokenizer.TokenizeAll("すもも もも")
+	found := false
+	for _, token := range tokens {
+		found = found || token.Surface == " " && token.Best
+	}
+	if !found {
+		t.Errorf("TokenizeAll() with WhitespaceToken failed:%v", tokens)
+	}
+}
+
+func TestLongWhitespace(t *testing.T) {
+	// a long whitespace run is split into chained skip nodes
+	dir := compileTestDictionary(t)
+	for _, c := range []struct {
+		max_grouping_size int
+		spaces            int
+	}{
+		{MAX_GROUPING_SIZE, 30},
+		{2, 4},
+	} {
+		tokenizer, err := NewTokenizerWithOptions(WithDicDir(dir), WithMaxGroupingSize(c.max_grouping_size))
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := "すもも" + strings.Repeat(" ", c.spaces) + "もも"
+		tokens, err := tokenizer.TokenizeTokens(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(tokens) != 2 || tokens[1].Surface != "もも" || tokens[1].SpaceStart != 9 || tokens[1].Start != 9+c.spaces {
+			t.Errorf("TokenizeTokens() with %d spaces failed:%v", c.spaces, tokens)
+		}
+		tokens_list, err := tokenizer.TokenizeNBestTokens(s, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, tokens := range tokens_list {
+			for _, token := range tokens {
+				if token.Surface == " " {
+					t.Errorf("TokenizeNBestTokens() with %d spaces must skip whitespace:%v", c.spaces, tokens)
+				}
+			}
+		}
+		tokenizer.Close()
+	}
+}
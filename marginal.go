@@ -0,0 +1,182 @@
+/*****************************************************************************
+MIT License
+
+Copyright (c) 2020 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*****************************************************************************/
+
+package goawabi
+
+import (
+	"fmt"
+	"math"
+)
+
+// Marginal probabilities by forward-backward algorithm (mecab -m)
+
+const DEFAULT_THETA = 0.75
+
+func logAddExp(x float64, y float64) float64 {
+	if math.IsInf(x, -1) {
+		return y
+	}
+	if math.IsInf(y, -1) {
+		return x
+	}
+	if x < y {
+		x, y = y, x
+	}
+	if x > y+50 {
+		return x
+	}
+	return x + math.Log1p(math.Exp(y-x))
+}
+
+func (lat *Lattice) rightNodes(pos int32) []*Node {
+	// nodes starting at pos, chains of skipped whitespace are passed through like leftNodes()
+	nodes := make([]*Node, 0, len(lat.snodes[pos]))
+	var visited map[int32]bool
+	positions := []int32{pos}
+	for len(positions) > 0 {
+		p := positions[len(positions)-1]
+		positions = positions[:len(positions)-1]
+		for _, snode := range lat.snodes[p] {
+			if !snode.skip {
+				nodes = append(nodes, snode)
+			} else if !visited[snode.epos] {
+				if visited == nil {
+					visited = make(map[int32]bool)
+				}
+				visited[snode.epos] = true
+				positions = append(positions, snode.epos)
+			}
+		}
+	}
+	return nodes
+}
+
+func (lat *Lattice) forwardBackward(m *matrix, scale float64) error {
+	// scale is theta / cost-factor, costs of a path are scaled to log score
+	if lat.p >= int32(len(lat.snodes)) || len(lat.snodes[lat.p]) == 0 || !lat.snodes[lat.p][0].isEos() {
+		return fmt.Errorf("%w: forwardBackward(): no EOS", ErrLatticeBroken)
+	}
+	for pos := int32(0); pos <= lat.p; pos++ {
+		for _, node := range lat.snodes[pos] {
+			if node.isBos() {
+				node.alpha = 0
+				continue
+			}
+			node.alpha = math.Inf(-1)
+			if node.skip {
+				continue
+			}
+			for _, lnode := range lat.leftNodes(pos) {
+				cost := m.getTransCost(int(lnode.right_id), int(node.left_id)) + node.cost
+				node.alpha = logAddExp(node.alpha, lnode.alpha-scale*float64(cost))
+			}
+		}
+	}
+	for pos := lat.p; pos >= 0; pos-- {
+		for _, node := range lat.snodes[pos] {
+			if node.isEos() {
+				node.beta = 0
+				continue
+			}
+			node.beta = math.Inf(-1)
+			if node.skip {
+				continue
+			}
+			for _, rnode := range lat.rightNodes(node.epos) {
+				cost := m.getTransCost(int(node.right_id), int(rnode.left_id)) + rnode.cost
+				node.beta = logAddExp(node.beta, rnode.beta-scale*float64(cost))
+			}
+		}
+	}
+	lat.log_z = lat.snodes[lat.p][0].alpha
+	if math.IsInf(lat.log_z, 0) || math.IsNaN(lat.log_z) {
+		return fmt.Errorf("%w: forwardBackward(): log Z is %v", ErrLatticeBroken, lat.log_z)
+	}
+	for pos := int32(0); pos <= lat.p; pos++ {
+		for _, node := range lat.snodes[pos] {
+			node.prob = 0
+			if !node.skip {
+				node.prob = math.Exp(node.alpha + node.beta - lat.log_z)
+			}
+		}
+	}
+	return nil
+}
+
+// ForwardBackward computes marginal probabilities of the nodes, and returns
+// log of the partition function. Tokens of Viterbi() and NBest() after it have Prob.
+func (lat *Lattice) ForwardBackward() (log_z float64, err error) {
+	if err := lat.tok.acquire(); err != nil {
+		return 0, err
+	}
+	defer lat.tok.release()
+	defer recoverError(&err)
+
+	if err := lat.forwardBackward(lat.tok.m, lat.tok.scale()); err != nil {
+		return 0, err
+	}
+	return lat.log_z, nil
+}
+
+// LogZ returns log of the partition function computed by ForwardBackward().
+func (lat *Lattice) LogZ() float64 {
+	return lat.log_z
+}
+
+func (node *Node) Alpha() float64 {
+	return node.alpha
+}
+
+func (node *Node) Beta() float64 {
+	return node.beta
+}
+
+// Prob returns the marginal probability computed by ForwardBackward().
+func (node *Node) Prob() float64 {
+	return node.prob
+}
+
+func (tok *Tokenizer) scale() float64 {
+	return tok.theta / float64(tok.config.CostFactor)
+}
+
+// TokenizeMarginal returns the best tokens with marginal probabilities,
+// and log of the partition function of str.
+func (tok *Tokenizer) TokenizeMarginal(str string) (tokens []Token, log_z float64, err error) {
+	if err := tok.acquire(); err != nil {
+		return nil, 0, err
+	}
+	defer tok.release()
+	defer recoverError(&err)
+
+	lat, err := tok.buildLattice(str, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	if err := lat.forwardBackward(tok.m, tok.scale()); err != nil {
+		return nil, 0, err
+	}
+	tokens, err = lat.viterbi(tok.m)
+	return tokens, lat.log_z, err
+}
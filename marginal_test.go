@@ -0,0 +1,108 @@
+/*****************************************************************************
+MIT License
+
+Copyright (c) 2020 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*****************************************************************************/
+
+package goawabi
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestForwardBackward(t *testing.T) {
+	tokenizer := newTestTokenizer(t)
+	s := "すもももも"
+	lat, err := tokenizer.BuildLattice(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log_z, err := lat.ForwardBackward()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if log_z != lat.LogZ() || math.Abs(lat.BOS().Beta()-log_z) > 1e-9 {
+		t.Errorf("BOS beta must be log Z:%v %v", lat.BOS().Beta(), log_z)
+	}
+
+	// log Z by enumerating all the pathes
+	scale := DEFAULT_THETA / 800
+	tokens_list, err := lat.NBest(10000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := math.Inf(-1)
+	for _, tokens := range tokens_list {
+		last := tokens[len(tokens)-1]
+		cost := last.Cost + int(tokenizer.m.getTransCost(last.RightId, 0))
+		expected = logAddExp(expected, -scale*float64(cost))
+	}
+	if math.Abs(log_z-expected) > 1e-9 {
+		t.Errorf("log Z %v != %v", log_z, expected)
+	}
+
+	// marginal probabilities of nodes at the end sum to 1
+	sum := 0.0
+	for _, node := range lat.NodesEndingAt(len(s)) {
+		sum += node.Prob()
+	}
+	if math.Abs(sum-1) > 1e-9 {
+		t.Errorf("sum of marginal probabilities is %v", sum)
+	}
+
+	tokens, log_z2, err := tokenizer.TokenizeMarginal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(log_z2-log_z) > 1e-9 {
+		t.Errorf("TokenizeMarginal() log Z failed:%v", log_z2)
+	}
+	for _, token := range tokens {
+		if !(token.Prob > 0 && token.Prob <= 1+1e-9) {
+			t.Errorf("TokenizeMarginal() failed:%v", tokens)
+		}
+	}
+	var sb strings.Builder
+	if err := formatToken(&sb, "%pP", s, &tokens[0], 0); err != nil || sb.String() == "0.000000" {
+		t.Errorf("%%pP failed:%s %v", sb.String(), err)
+	}
+
+	// chained skip nodes of a long whitespace run
+	s = "すもも" + strings.Repeat(" ", 30) + "もも"
+	tokens, log_z, err = tokenizer.TokenizeMarginal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.IsInf(log_z, 0) || math.IsNaN(log_z) || len(tokens) != 2 {
+		t.Errorf("TokenizeMarginal() with whitespace failed:%v %v", log_z, tokens)
+	}
+	for _, token := range tokens {
+		if !(token.Prob > 0 && token.Prob <= 1+1e-9) {
+			t.Errorf("TokenizeMarginal() with whitespace failed:%v", tokens)
+		}
+	}
+
+	if _, err := NewTokenizerWithOptions(WithDicDir("."), WithTheta(0)); err == nil {
+		t.Errorf("WithTheta(0) must fail")
+	}
+}
@@ -27,13 +27,26 @@ package goawabi
 import (
 	"bufio"
 	"errors"
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
-	"regexp"
+	"strings"
 )
 
+var default_mecabrc_pathes = []string{"/usr/local/etc/mecabrc", "/etc/mecabrc"}
+
+// find_mecabrc searches mecabrc in the same order as MeCab:
+// $MECABRC, ~/.mecabrc, then the default pathes.
 func find_mecabrc() (path string, err error) {
-	pathes := []string{"/usr/local/etc/mecabrc", "/etc/mecabrc"}
+	if s := os.Getenv("MECABRC"); s != "" {
+		return s, nil
+	}
+	pathes := make([]string, 0, len(default_mecabrc_pathes)+1)
+	if home, e := os.UserHomeDir(); e == nil {
+		pathes = append(pathes, filepath.Join(home, ".mecabrc"))
+	}
+	pathes = append(pathes, default_mecabrc_pathes...)
 	for _, s := range pathes {
 		_, e := os.Stat(s)
 		if !os.IsNotExist(e) {
@@ -62,15 +75,69 @@ func get_mecabrc_map(path string) (mecabrc_map map[string]string, err error) {
 	}
 	defer fp.Close()
 
-	scanner := bufio.NewScanner(fp)
-	re := regexp.MustCompile(`^(\S+)\s*=\s*(\S+)`)
+	mecabrc_map, err = parse_mecabrc(fp)
+	if err != nil {
+		return mecabrc_map, fmt.Errorf("%s: %w", path, err)
+	}
+	resolve_mecabrc(mecabrc_map, filepath.Dir(path))
+	return mecabrc_map, nil
+}
+
+// parse_mecabrc reads "key = value" lines.
+// Lines starting with ';' or '#' are comments, and the value may contain spaces.
+// Trailing spaces of the value are kept, e.g. "node-format-wakati = %M ".
+// The first definition of a key wins, as MeCab does.
+func parse_mecabrc(r io.Reader) (mecabrc_map map[string]string, err error) {
+	mecabrc_map = make(map[string]string)
+	scanner := bufio.NewScanner(r)
+	lineno := 0
 	for scanner.Scan() {
-		group := re.FindAllStringSubmatch(scanner.Text(), -1)
-		if len(group) == 1 { // at most 1
-			mecabrc_map[group[0][1]] = group[0][2]
+		lineno++
+		line := strings.TrimRight(scanner.Text(), "\r")
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || trimmed[0] == ';' || trimmed[0] == '#' {
+			continue
+		}
+		i := strings.IndexByte(line, '=')
+		if i < 0 {
+			return mecabrc_map, fmt.Errorf("line %d: format error: %s", lineno, trimmed)
+		}
+		key := strings.TrimSpace(line[:i])
+		value := strings.TrimLeft(line[i+1:], " \t")
+		if key == "" {
+			return mecabrc_map, fmt.Errorf("line %d: format error: %s", lineno, trimmed)
+		}
+		if _, ok := mecabrc_map[key]; !ok {
+			mecabrc_map[key] = value
+		}
+	}
+	return mecabrc_map, scanner.Err()
+}
+
+// resolve_mecabrc expands $(rcpath) in dicdir and userdic,
+// and makes relative dicdir relative to rcpath.
+func resolve_mecabrc(mecabrc_map map[string]string, rcpath string) {
+	if val, ok := mecabrc_map["dicdir"]; ok {
+		val = strings.ReplaceAll(strings.TrimSpace(val), "$(rcpath)", rcpath)
+		if !filepath.IsAbs(val) {
+			val = filepath.Join(rcpath, val)
+		}
+		mecabrc_map["dicdir"] = val
+	}
+	if val, ok := mecabrc_map["userdic"]; ok {
+		mecabrc_map["userdic"] = strings.ReplaceAll(val, "$(rcpath)", rcpath)
+	}
+}
+
+func get_userdic_pathes(mecabrc_map map[string]string) []string {
+	pathes := make([]string, 0)
+	for _, s := range strings.Split(mecabrc_map["userdic"], ",") {
+		s = strings.TrimSpace(s)
+		if s != "" {
+			pathes = append(pathes, s)
 		}
 	}
-	return mecabrc_map, err
+	return pathes
 }
 
 func get_dic_path(mecabrc_map map[string]string, filename string) string {
@@ -26,6 +26,8 @@ package goawabi
 
 import (
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -56,3 +58,89 @@ func TestGetDicPath(t *testing.T) {
 		}
 	}
 }
+
+func TestParseMecabRc(t *testing.T) {
+	rc := `; comment
+# comment
+dicdir =  /usr/lib/mecab/dic/my dic
+userdic=$(rcpath)/a.dic, b.dic
+cost-factor = 800
+dicdir = /ignored
+`
+	mecabrc_map, err := parse_mecabrc(strings.NewReader(rc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mecabrc_map["dicdir"] != "/usr/lib/mecab/dic/my dic" || mecabrc_map["cost-factor"] != "800" || len(mecabrc_map) != 3 {
+		t.Errorf("parse_mecabrc() failed:%v", mecabrc_map)
+	}
+	resolve_mecabrc(mecabrc_map, "/etc")
+	pathes := get_userdic_pathes(mecabrc_map)
+	if len(pathes) != 2 || pathes[0] != "/etc/a.dic" || pathes[1] != "b.dic" {
+		t.Errorf("get_userdic_pathes() failed:%v", pathes)
+	}
+
+	if _, err := parse_mecabrc(strings.NewReader("dicdir\n")); err == nil {
+		t.Errorf("parse_mecabrc() must fail without '='")
+	}
+
+	mecabrc_map, _ = parse_mecabrc(strings.NewReader("dicdir = $(rcpath)/ipadic\n"))
+	resolve_mecabrc(mecabrc_map, "/etc")
+	if mecabrc_map["dicdir"] != filepath.Join("/etc", "ipadic") {
+		t.Errorf("resolve_mecabrc() failed:%v", mecabrc_map)
+	}
+	mecabrc_map, _ = parse_mecabrc(strings.NewReader("dicdir = ipadic\n"))
+	resolve_mecabrc(mecabrc_map, "/etc")
+	if mecabrc_map["dicdir"] != filepath.Join("/etc", "ipadic") {
+		t.Errorf("resolve_mecabrc() failed:%v", mecabrc_map)
+	}
+}
+
+func TestFindMecabRcEnv(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Setenv("MECABRC", "")
+	home_rc := filepath.Join(dir, ".mecabrc")
+	if err := os.WriteFile(home_rc, []byte("dicdir = .\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if path, err := find_mecabrc(); err != nil || path != home_rc {
+		t.Errorf("find_mecabrc() failed:%s %v", path, err)
+	}
+
+	env_rc := filepath.Join(dir, "mecabrc")
+	t.Setenv("MECABRC", env_rc)
+	if path, err := find_mecabrc(); err != nil || path != env_rc {
+		t.Errorf("find_mecabrc() failed:%s %v", path, err)
+	}
+}
+
+func TestMultipleUserDic(t *testing.T) {
+	dir := compileTestDictionary(t)
+	csv_path := filepath.Join(dir, "user2.csv")
+	if err := os.WriteFile(csv_path, []byte("ももも,1,1,1000,名詞,一般,*,*,*,*,ももも,モモモ,モモモ\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := CompileUserDictionary(dir, []string{filepath.Join("testdata", "userdic", "user.csv")}, filepath.Join(dir, "user1.dic")); err != nil {
+		t.Fatal(err)
+	}
+	if err := CompileUserDictionary(dir, []string{csv_path}, filepath.Join(dir, "user2.dic")); err != nil {
+		t.Fatal(err)
+	}
+	rc := filepath.Join(dir, "mecabrc")
+	if err := os.WriteFile(rc, []byte("dicdir = .\nuserdic = $(rcpath)/user1.dic, $(rcpath)/user2.dic\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tokenizer, err := NewTokenizer(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tokenizer.Close()
+	morphemes, err := tokenizer.Tokenize("ハハハとももも")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(morphemes) != 3 || morphemes[0][0] != "ハハハ" || morphemes[2][0] != "ももも" {
+		t.Errorf("Tokenize() failed:%v", morphemes)
+	}
+}
@@ -0,0 +1,174 @@
+/*****************************************************************************
+MIT License
+
+Copyright (c) 2020 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*****************************************************************************/
+
+package goawabi
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// In-memory user dictionary
+
+type memoryDic struct {
+	mu        sync.RWMutex
+	entries   map[string][]*DicEntry
+	max_len   int
+	pos_cache map[string]*DicEntry
+}
+
+func newMemoryDic() *memoryDic {
+	md := new(memoryDic)
+	md.entries = make(map[string][]*DicEntry)
+	md.pos_cache = make(map[string]*DicEntry)
+	return md
+}
+
+func (md *memoryDic) add(e *DicEntry) {
+	md.mu.Lock()
+	defer md.mu.Unlock()
+	md.entries[e.original] = append(md.entries[e.original], e)
+	if len(e.original) > md.max_len {
+		md.max_len = len(e.original)
+	}
+}
+
+func (md *memoryDic) remove(surface string) bool {
+	md.mu.Lock()
+	defer md.mu.Unlock()
+	if _, ok := md.entries[surface]; !ok {
+		return false
+	}
+	delete(md.entries, surface)
+	md.max_len = 0
+	for s := range md.entries {
+		if len(s) > md.max_len {
+			md.max_len = len(s)
+		}
+	}
+	return true
+}
+
+func (md *memoryDic) lookup(s []byte) []*DicEntry {
+	md.mu.RLock()
+	defer md.mu.RUnlock()
+	results := make([]*DicEntry, 0)
+	if len(md.entries) == 0 {
+		return results
+	}
+	for ln := 1; ln <= md.max_len && ln <= len(s); ln++ {
+		for _, e := range md.entries[string(s[:ln])] {
+			d := *e
+			results = append(results, &d)
+		}
+	}
+	return results
+}
+
+func (m *mecabDic) findByFeaturePrefix(prefix string) *DicEntry {
+	// linear scan of token records
+	for i := 0; i < m.lexsize; i++ {
+		e := m.getEntriesByIndex(i, 1, "", false)[0]
+		if e.feature == prefix || strings.HasPrefix(e.feature, prefix+",") {
+			return e
+		}
+	}
+	return nil
+}
+
+func (tok *Tokenizer) findPOS(pos string) (*DicEntry, error) {
+	tok.mem_dic.mu.RLock()
+	e, ok := tok.mem_dic.pos_cache[pos]
+	tok.mem_dic.mu.RUnlock()
+	if ok {
+		return e, nil
+	}
+
+	if err := tok.acquire(); err != nil {
+		return nil, err
+	}
+	defer tok.release()
+	e = tok.unk_dic.findByFeaturePrefix(pos)
+	if e == nil {
+		e = tok.sys_dic.findByFeaturePrefix(pos)
+	}
+	if e == nil {
+		return nil, errors.New("Can't find part of speech: " + pos)
+	}
+	tok.mem_dic.mu.Lock()
+	tok.mem_dic.pos_cache[pos] = e
+	tok.mem_dic.mu.Unlock()
+	return e, nil
+}
+
+// Add a word to the in-memory user dictionary. It is looked up
+// with user and system dictionaries, and can be called while other
+// goroutines are tokenizing.
+func (tok *Tokenizer) AddWord(surface string, left_id int, right_id int, cost int, feature string) error {
+	return tok.addWord(surface, left_id, right_id, 0, cost, feature)
+}
+
+func (tok *Tokenizer) addWord(surface string, left_id int, right_id int, posid uint16, cost int, feature string) error {
+	if surface == "" {
+		return errors.New("empty surface")
+	}
+	if left_id < 0 || left_id >= tok.m.rsize || right_id < 0 || right_id >= tok.m.lsize {
+		return fmt.Errorf("context id is out of range (%d, %d)", left_id, right_id)
+	}
+	if cost < -0x8000 || cost > 0x7FFF {
+		return fmt.Errorf("cost is out of range %d", cost)
+	}
+
+	e := new(DicEntry)
+	e.original = surface
+	e.lc_attr = uint16(left_id)
+	e.rc_attr = uint16(right_id)
+	e.posid = posid
+	e.wcost = int16(cost)
+	e.feature = feature
+	e.dic = MemoryDictionary
+	tok.mem_dic.add(e)
+	return nil
+}
+
+// Add a word with context ids and posid of the first dictionary entry
+// whose feature starts with pos, e.g. "名詞,固有名詞,人名".
+// If feature is empty, pos is used as feature.
+func (tok *Tokenizer) AddWordWithPOS(surface string, pos string, cost int, feature string) error {
+	e, err := tok.findPOS(pos)
+	if err != nil {
+		return err
+	}
+	if feature == "" {
+		feature = pos
+	}
+	return tok.addWord(surface, int(e.lc_attr), int(e.rc_attr), e.posid, cost, feature)
+}
+
+// Remove all words of surface from the in-memory user dictionary.
+func (tok *Tokenizer) RemoveWord(surface string) bool {
+	return tok.mem_dic.remove(surface)
+}
@@ -0,0 +1,106 @@
+/*****************************************************************************
+MIT License
+
+Copyright (c) 2020 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*****************************************************************************/
+
+package goawabi
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestMemoryDic(t *testing.T) {
+	tokenizer := newTestTokenizer(t)
+
+	morphemes, err := tokenizer.Tokenize("母はハハハと笑う")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if morphemes[2][1] != "名詞,一般,*,*,*,*,*" {
+		t.Errorf("Tokenize() failed:%v", morphemes)
+	}
+
+	err = tokenizer.AddWord("ハハハ", 1, 1, 1000, "感動詞,*,*,*,*,*,ハハハ,ハハハ,ハハハ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	morphemes, err = tokenizer.Tokenize("母はハハハと笑う")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if morphemes[2][1] != "感動詞,*,*,*,*,*,ハハハ,ハハハ,ハハハ" {
+		t.Errorf("AddWord() failed:%v", morphemes)
+	}
+
+	if !tokenizer.RemoveWord("ハハハ") || tokenizer.RemoveWord("ハハハ") {
+		t.Errorf("RemoveWord() failed")
+	}
+	morphemes, err = tokenizer.Tokenize("母はハハハと笑う")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if morphemes[2][1] != "名詞,一般,*,*,*,*,*" {
+		t.Errorf("RemoveWord() failed:%v", morphemes)
+	}
+
+	if tokenizer.AddWord("ハハハ", 11, 1, 1000, "感動詞") == nil {
+		t.Errorf("AddWord() must validate context id")
+	}
+
+	err = tokenizer.AddWordWithPOS("ももの", "名詞,非自立", 100, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens, err := tokenizer.TokenizeTokens("すもももものうち")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tokens[1].Surface != "ももの" || tokens[1].LeftId != 4 || tokens[1].PosId != 57 || tokens[1].Feature != "名詞,非自立" {
+		t.Errorf("AddWordWithPOS() failed:%v", tokens)
+	}
+	if tokenizer.AddWordWithPOS("ももの", "形容詞", 100, "") == nil {
+		t.Errorf("AddWordWithPOS() must fail for unknown part of speech")
+	}
+}
+
+func TestMemoryDicConcurrent(t *testing.T) {
+	tokenizer := newTestTokenizer(t)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if _, err := tokenizer.Tokenize("母はハハハと笑う"); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	for j := 0; j < 100; j++ {
+		tokenizer.AddWord("ハハハ", 1, 1, 1000, "感動詞")
+		tokenizer.RemoveWord("ハハハ")
+	}
+	wg.Wait()
+}
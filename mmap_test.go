@@ -0,0 +1,62 @@
+/*****************************************************************************
+MIT License
+
+Copyright (c) 2020 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*****************************************************************************/
+
+package goawabi
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// The portable loader is tested with `go test -tags nommap` in CI.
+
+func TestOpenFile(t *testing.T) {
+	dir := compileTestDictionary(t)
+	path := filepath.Join(dir, "sys.dic")
+
+	data, mapped, err := openFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mapped != mmapSupported {
+		t.Errorf("openFile() mapped:%v", mapped)
+	}
+	expected, _ := os.ReadFile(path)
+	if !bytes.Equal(data, expected) {
+		t.Errorf("openFile() returns invalid data")
+	}
+
+	sys_dic, err := newMecabDic(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entries := sys_dic.lookup([]byte("すもも")); len(entries) != 1 {
+		t.Errorf("lookup() failed:%v", entries)
+	}
+	if err := sys_dic.close(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,243 @@
+/*****************************************************************************
+MIT License
+
+Copyright (c) 2020 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*****************************************************************************/
+
+package goawabi
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Options of NewTokenizerWithOptions
+
+type options struct {
+	mecabrc           string
+	use_mecabrc       bool
+	dicdir            string
+	sys_dic           string
+	unk_dic           string
+	matrix            string
+	char_property     string
+	user_dics         []string
+	max_grouping_size int
+	theta             float64
+	whitespace        WhitespacePolicy
+	invalid           InvalidUTF8Policy
+	max_input_size    int
+	max_nodes         int
+}
+
+type Option func(*options) error
+
+// WithMecabrc reads mecabrc at path, if path is empty it is searched like MeCab.
+// mecabrc is read by default unless WithDicDir is given.
+func WithMecabrc(path string) Option {
+	return func(o *options) error {
+		o.mecabrc = path
+		o.use_mecabrc = true
+		return nil
+	}
+}
+
+// WithDicDir sets the dictionary directory, overriding dicdir of mecabrc.
+func WithDicDir(dir string) Option {
+	return func(o *options) error {
+		if dir == "" {
+			return errors.New("empty dicdir")
+		}
+		o.dicdir = dir
+		return nil
+	}
+}
+
+// WithSysDic sets the path of sys.dic instead of the one in dicdir.
+func WithSysDic(path string) Option {
+	return func(o *options) error {
+		o.sys_dic = path
+		return nil
+	}
+}
+
+// WithUnkDic sets the path of unk.dic instead of the one in dicdir.
+func WithUnkDic(path string) Option {
+	return func(o *options) error {
+		o.unk_dic = path
+		return nil
+	}
+}
+
+// WithMatrix sets the path of matrix.bin instead of the one in dicdir.
+func WithMatrix(path string) Option {
+	return func(o *options) error {
+		o.matrix = path
+		return nil
+	}
+}
+
+// WithCharProperty sets the path of char.bin instead of the one in dicdir.
+func WithCharProperty(path string) Option {
+	return func(o *options) error {
+		o.char_property = path
+		return nil
+	}
+}
+
+// WithUserDic adds user dictionaries after userdic of mecabrc.
+func WithUserDic(pathes ...string) Option {
+	return func(o *options) error {
+		o.user_dics = append(o.user_dics, pathes...)
+		return nil
+	}
+}
+
+// WithMaxGroupingSize sets the max number of characters of a grouped unknown word.
+func WithMaxGroupingSize(n int) Option {
+	return func(o *options) error {
+		if n <= 0 {
+			return fmt.Errorf("invalid max grouping size %d", n)
+		}
+		o.max_grouping_size = n
+		return nil
+	}
+}
+
+// WithTheta sets temperature of marginal probabilities like mecab --theta,
+// costs are scaled by theta / cost-factor of dicrc.
+func WithTheta(theta float64) Option {
+	return func(o *options) error {
+		if !(theta > 0) {
+			return fmt.Errorf("invalid theta %v", theta)
+		}
+		o.theta = theta
+		return nil
+	}
+}
+
+func WithWhitespacePolicy(policy WhitespacePolicy) Option {
+	return func(o *options) error {
+		o.whitespace = policy
+		return nil
+	}
+}
+
+func WithInvalidUTF8Policy(policy InvalidUTF8Policy) Option {
+	return func(o *options) error {
+		o.invalid = policy
+		return nil
+	}
+}
+
+// WithMaxInputSize limits bytes of a string to tokenize, ErrLatticeLimit is returned if exceeded.
+func WithMaxInputSize(n int) Option {
+	return func(o *options) error {
+		if n < 0 {
+			return fmt.Errorf("invalid max input size %d", n)
+		}
+		o.max_input_size = n
+		return nil
+	}
+}
+
+// WithMaxNodes limits the number of lattice nodes, ErrLatticeLimit is returned if exceeded.
+func WithMaxNodes(n int) Option {
+	return func(o *options) error {
+		if n < 0 {
+			return fmt.Errorf("invalid max nodes %d", n)
+		}
+		o.max_nodes = n
+		return nil
+	}
+}
+
+func (o *options) dicFiles() (*dicFiles, error) {
+	mecabrc_map := make(map[string]string)
+	if o.use_mecabrc || o.dicdir == "" {
+		var err error
+		mecabrc_map, err = get_mecabrc_map(o.mecabrc)
+		if err != nil && (o.use_mecabrc || !o.complete()) {
+			return nil, fmt.Errorf("mecabrc: %w", err)
+		}
+	}
+	if o.dicdir != "" {
+		mecabrc_map["dicdir"] = o.dicdir
+	}
+	files := newDicFiles(mecabrc_map)
+	for _, f := range []struct {
+		path string
+		dst  *string
+	}{
+		{o.sys_dic, &files.sys_dic},
+		{o.unk_dic, &files.unk_dic},
+		{o.matrix, &files.matrix},
+		{o.char_property, &files.char_property},
+	} {
+		if f.path != "" {
+			*f.dst = f.path
+		}
+	}
+	return files, files.check()
+}
+
+func (o *options) complete() bool {
+	// all files are given without dicdir
+	return o.sys_dic != "" && o.unk_dic != "" && o.matrix != "" && o.char_property != ""
+}
+
+func newDefaultOptions() *options {
+	return &options{max_grouping_size: MAX_GROUPING_SIZE, theta: DEFAULT_THETA}
+}
+
+func newOptions(opts []Option) (*options, error) {
+	o := newDefaultOptions()
+	for _, opt := range opts {
+		if err := opt(o); err != nil {
+			return nil, err
+		}
+	}
+	return o, nil
+}
+
+func (o *options) locatesDictionary() bool {
+	return o.use_mecabrc || o.dicdir != "" || o.sys_dic != "" || o.unk_dic != "" || o.matrix != "" || o.char_property != ""
+}
+
+// NewTokenizerWithOptions loads dictionaries with options, e.g.
+//
+//	NewTokenizerWithOptions(WithDicDir("/var/lib/mecab/dic/ipadic-utf8"), WithUserDic("user.dic"))
+func NewTokenizerWithOptions(opts ...Option) (*Tokenizer, error) {
+	o, err := newOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	files, err := o.dicFiles()
+	if err != nil {
+		return nil, err
+	}
+	d, err := openDictionary(files, openFile, statFile)
+	if err != nil {
+		return nil, err
+	}
+	defer d.Close() // the Tokenizer holds a reference of d
+	return d.newTokenizer(o, d.user_dics)
+}
@@ -0,0 +1,136 @@
+/*****************************************************************************
+MIT License
+
+Copyright (c) 2020 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*****************************************************************************/
+
+package goawabi
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNewTokenizerWithOptions(t *testing.T) {
+	dir := compileTestDictionary(t)
+	user_dic_path := filepath.Join(dir, "user.dic")
+	if err := CompileUserDictionary(dir, []string{filepath.Join("testdata", "userdic", "user.csv")}, user_dic_path); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("MECABRC", filepath.Join(t.TempDir(), "mecabrc"))
+
+	tokenizer, err := NewTokenizerWithOptions(WithDicDir(dir), WithUserDic(user_dic_path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	morphemes, err := tokenizer.Tokenize("母はハハハと笑う")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(morphemes) != 5 || morphemes[2][1] != "感動詞,*,*,*,*,*,ハハハ,ハハハ,ハハハ" {
+		t.Errorf("Tokenize() failed:%v", morphemes)
+	}
+	if tokenizer.Config().CostFactor != 800 {
+		t.Errorf("dicrc is not loaded")
+	}
+	tokenizer.Close()
+
+	// file overrides without dicdir
+	tokenizer, err = NewTokenizerWithOptions(
+		WithSysDic(filepath.Join(dir, "sys.dic")),
+		WithUnkDic(filepath.Join(dir, "unk.dic")),
+		WithMatrix(filepath.Join(dir, "matrix.bin")),
+		WithCharProperty(filepath.Join(dir, "char.bin")),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tokenizer.Config().CostFactor != DEFAULT_COST_FACTOR {
+		t.Errorf("default dicrc is expected")
+	}
+	tokenizer.Close()
+
+	// incomplete config
+	if _, err := NewTokenizerWithOptions(); err == nil || !strings.Contains(err.Error(), "mecabrc") {
+		t.Errorf("NewTokenizerWithOptions() must fail without mecabrc:%v", err)
+	}
+	if _, err := NewTokenizer(""); err == nil {
+		t.Errorf("NewTokenizer() must fail without mecabrc")
+	}
+	rc := filepath.Join(t.TempDir(), "mecabrc")
+	if err := os.WriteFile(rc, []byte("; no dicdir\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewTokenizer(rc); err == nil || !strings.Contains(err.Error(), "dicdir is not specified") {
+		t.Errorf("NewTokenizer() must fail without dicdir:%v", err)
+	}
+	if _, err := NewTokenizerWithOptions(WithSysDic(filepath.Join(dir, "sys.dic"))); err == nil {
+		t.Errorf("NewTokenizerWithOptions() must fail without dicdir")
+	}
+	if _, err := NewTokenizerWithOptions(WithDicDir(dir), WithMaxGroupingSize(0)); err == nil {
+		t.Errorf("WithMaxGroupingSize(0) must fail")
+	}
+}
+
+func TestTokenizerLimits(t *testing.T) {
+	dir := compileTestDictionary(t)
+
+	tokenizer, err := NewTokenizerWithOptions(WithDicDir(dir), WithMaxGroupingSize(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	morphemes, err := tokenizer.Tokenize("アイウエオ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(morphemes) < 2 {
+		t.Errorf("Tokenize() must not group more than 2 characters:%v", morphemes)
+	}
+	tokenizer.Close()
+
+	tokenizer, err = NewTokenizerWithOptions(WithDicDir(dir), WithMaxInputSize(9))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tokenizer.Tokenize("すもも"); err != nil {
+		t.Error(err)
+	}
+	if _, err := tokenizer.Tokenize("すもももも"); !errors.Is(err, ErrLatticeLimit) {
+		t.Errorf("max input size must be ErrLatticeLimit:%v", err)
+	}
+	tokenizer.Close()
+
+	tokenizer, err = NewTokenizerWithOptions(WithDicDir(dir), WithMaxNodes(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tokenizer.Close()
+	if _, err := tokenizer.Tokenize("も"); err != nil {
+		t.Error(err)
+	}
+	if _, err := tokenizer.Tokenize("ももももも"); !errors.Is(err, ErrLatticeLimit) {
+		t.Errorf("max nodes must be ErrLatticeLimit:%v", err)
+	}
+}
@@ -0,0 +1,124 @@
+/*****************************************************************************
+MIT License
+
+Copyright (c) 2020 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*****************************************************************************/
+
+package goawabi
+
+import (
+	"strings"
+)
+
+// Token
+
+type TokenStatus int
+
+const (
+	NormalToken TokenStatus = iota
+	UnknownToken
+	BOSToken
+	EOSToken
+)
+
+type Token struct {
+	Surface   string
+	Feature   string
+	Features  []string
+	Start     int // byte offset in the input
+	End       int
+	RuneStart int // rune offset in the input
+	RuneEnd   int
+	// start of the whitespace skipped before the token, equals to Start if none
+	SpaceStart     int
+	RuneSpaceStart int
+	LeftId         int
+	RightId        int
+	PosId          int
+	WordCost       int
+	Cost           int // cumulative path cost from BOS
+	Status         TokenStatus
+	// SystemDictionary, UnknownDictionary, MemoryDictionary or the name of the user dictionary
+	Dictionary string
+	// marginal probability, set if computed by forward-backward
+	Prob float64
+	// whether the token is on the best path, set by TokenizeAll
+	Best bool
+}
+
+func splitFeature(feature string) []string {
+	// split CSV feature string, double quoted field may contain ','
+	fields := make([]string, 0)
+	var sb strings.Builder
+	quoted := false
+	for i := 0; i < len(feature); i++ {
+		c := feature[i]
+		switch {
+		case c == '"' && quoted && i+1 < len(feature) && feature[i+1] == '"':
+			sb.WriteByte('"')
+			i++
+		case c == '"':
+			quoted = !quoted
+		case c == ',' && !quoted:
+			fields = append(fields, sb.String())
+			sb.Reset()
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	fields = append(fields, sb.String())
+	return fields
+}
+
+func newToken(lat *Lattice, node *Node, prev *Node) Token {
+	return Token{
+		Surface:        node.original,
+		Feature:        node.feature,
+		Features:       splitFeature(node.feature),
+		Start:          lat.byteOffset(node.pos),
+		End:            lat.byteOffset(node.epos),
+		RuneStart:      lat.runeOffset(node.pos),
+		RuneEnd:        lat.runeOffset(node.epos),
+		SpaceStart:     lat.byteOffset(prev.epos),
+		RuneSpaceStart: lat.runeOffset(prev.epos),
+		LeftId:         int(node.left_id),
+		RightId:        int(node.right_id),
+		PosId:          int(node.posid),
+		WordCost:       int(node.cost),
+		Status:         node.stat,
+		Dictionary:     node.dic,
+	}
+}
+
+func nodesToTokens(lat *Lattice, nodes []*Node, m *matrix) []Token {
+	// nodes is a path from BOS to EOS
+	tokens := make([]Token, 0, len(nodes))
+	var cost int32
+	for i := 1; i < len(nodes)-1; i++ {
+		node := nodes[i]
+		cost += m.getTransCost(int(nodes[i-1].right_id), int(node.left_id)) + node.cost
+		tok := newToken(lat, node, nodes[i-1])
+		tok.Cost = int(cost)
+		tok.Prob = node.prob
+		tokens = append(tokens, tok)
+	}
+	return tokens
+}
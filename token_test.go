@@ -0,0 +1,128 @@
+/*****************************************************************************
+MIT License
+
+Copyright (c) 2020 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*****************************************************************************/
+
+package goawabi
+
+import (
+	"testing"
+)
+
+func TestSplitFeature(t *testing.T) {
+	fields := splitFeature("名詞,一般,*,*,*,*,すもも,スモモ,スモモ")
+	if len(fields) != 9 || fields[0] != "名詞" || fields[8] != "スモモ" {
+		t.Errorf("splitFeature() failed:%v", fields)
+	}
+	fields = splitFeature(`記号,"a,b","say ""hi"""`)
+	if len(fields) != 3 || fields[1] != "a,b" || fields[2] != `say "hi"` {
+		t.Errorf("splitFeature() failed:%v", fields)
+	}
+}
+
+func TestTokenizeTokens(t *testing.T) {
+	tokenizer, err := NewTokenizer("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tokens, err := tokenizer.TokenizeTokens("すもももももももものうち")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tokens) != 7 {
+		t.Fatalf("TokenizeTokens() failed:%v", tokens)
+	}
+	if tokens[0].Surface != "すもも" || tokens[0].Features[0] != "名詞" || tokens[0].Status != NormalToken {
+		t.Errorf("TokenizeTokens() failed:%v", tokens[0])
+	}
+	if tokens[1].Start != 9 || tokens[1].End != 12 || tokens[1].RuneStart != 3 || tokens[1].RuneEnd != 4 {
+		t.Errorf("TokenizeTokens() offset failed:%v", tokens[1])
+	}
+	if tokens[6].Cost <= 0 || tokens[6].Cost < tokens[0].Cost {
+		t.Errorf("TokenizeTokens() cost failed:%v", tokens[6])
+	}
+
+	tokens, err = tokenizer.TokenizeTokens("山嵐は might is right という")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tokens[2].Surface != "might" || tokens[2].Status != UnknownToken || tokens[2].Start != 10 {
+		t.Errorf("TokenizeTokens() unknown failed:%v", tokens[2])
+	}
+	if tokens[2].SpaceStart != 9 || tokens[2].RuneSpaceStart != 3 || tokens[2].RuneStart != 4 {
+		t.Errorf("TokenizeTokens() space offset failed:%v", tokens[2])
+	}
+
+	tokens_list, err := tokenizer.TokenizeNBestTokens("すもももももももものうち", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tokens_list) != 2 || tokens_list[0][6].Cost > tokens_list[1][6].Cost {
+		t.Errorf("TokenizeNBestTokens() failed:%v", tokens_list)
+	}
+}
+
+func TestWhitespacePolicy(t *testing.T) {
+	tokenizer, err := NewTokenizer("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := "  山嵐は might  is"
+	tokens, err := tokenizer.TokenizeTokens(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, token := range tokens {
+		if s[token.Start:token.End] != token.Surface {
+			t.Errorf("TokenizeTokens() offset failed:%v", token)
+		}
+	}
+	if tokens[0].SpaceStart != 0 || tokens[0].Start != 2 {
+		t.Errorf("TokenizeTokens() leading space failed:%v", tokens[0])
+	}
+
+	tokenizer.SetWhitespacePolicy(WhitespaceToken)
+	tokens, err = tokenizer.TokenizeTokens(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tokens[0].Surface != "  " || tokens[0].Status != UnknownToken {
+		t.Errorf("TokenizeTokens() whitespace token failed:%v", tokens[0])
+	}
+	surfaces := ""
+	for _, token := range tokens {
+		surfaces += token.Surface
+	}
+	if surfaces != s {
+		t.Errorf("TokenizeTokens() whitespace token failed:%v", tokens)
+	}
+
+	tokens_list, err := tokenizer.TokenizeNBestTokens(s, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tokens_list[0][0].Surface != "  " {
+		t.Errorf("TokenizeNBestTokens() whitespace token failed:%v", tokens_list[0])
+	}
+}
@@ -24,124 +24,369 @@ SOFTWARE.
 
 package goawabi
 
+import (
+	"fmt"
+	"io/fs"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+type WhitespacePolicy int
+
+const (
+	WhitespaceSkip  WhitespacePolicy = iota // whitespace is not returned as token
+	WhitespaceToken                         // whitespace is returned as unknown token
+)
+
+type InvalidUTF8Policy int
+
+const (
+	InvalidUTF8Reject  InvalidUTF8Policy = iota // return ErrInvalidUTF8
+	InvalidUTF8Replace                          // replace invalid bytes with U+FFFD, offsets are of the input
+	InvalidUTF8Bytes                            // each invalid byte is an unknown token
+)
+
+// Tokenizer is safe for concurrent use. Tokenize*, AddWord*, RemoveWord,
+// AddUserDictionary and EnableUserDictionary can be called from multiple
+// goroutines, but Set* methods must be called before sharing the Tokenizer.
 type Tokenizer struct {
-	sys_dic  *mecabDic
-	user_dic *mecabDic
-	cp       *charProperty
-	unk_dic  *mecabDic
-	m        *matrix
+	dic        *Dictionary
+	sys_dic    *mecabDic
+	user_dics  []*userDic
+	user_mu    sync.RWMutex
+	mem_dic    *memoryDic
+	cp         *charProperty
+	unk_dic    *mecabDic
+	m          *matrix
+	config     *DicConfig
+	whitespace WhitespacePolicy
+	invalid    InvalidUTF8Policy
+
+	max_grouping_size int
+	theta             float64
+	max_input_size    int // 0 is unlimited
+	max_nodes         int // 0 is unlimited
+
+	mu     sync.Mutex
+	refs   int
+	closed bool
 }
 
+// NewTokenizer loads dictionaries with mecabrc at path.
+// If path is empty, mecabrc is searched like MeCab.
 func NewTokenizer(path string) (*Tokenizer, error) {
+	return NewTokenizerWithOptions(WithMecabrc(path))
+}
+
+func newTokenizer() *Tokenizer {
 	tok := new(Tokenizer)
-	mecabrc_map, _ := get_mecabrc_map(path)
-	sys_dic, err := newMecabDic(get_dic_path(mecabrc_map, "sys.dic"))
+	tok.mem_dic = newMemoryDic()
+	tok.max_grouping_size = MAX_GROUPING_SIZE
+	tok.theta = DEFAULT_THETA
+	return tok
+}
+
+// NewTokenizerFS loads dictionaries from fsys, e.g. embed.FS.
+// config is mecabrc style text, and dicdir, userdic are paths in fsys.
+// $(rcpath) in config is the root of fsys.
+// Files are mmapped if fsys returns *os.File, otherwise read into memory.
+func NewTokenizerFS(fsys fs.FS, config string) (*Tokenizer, error) {
+	mecabrc_map, err := parse_mecabrc(strings.NewReader(config))
 	if err != nil {
-		return tok, err
+		return nil, err
 	}
-	tok.sys_dic = sys_dic
-
-	if val, ok := mecabrc_map["userdic"]; ok {
-		user_dic, err := newMecabDic(val)
-		if err != nil {
-			return tok, err
-		}
-		tok.user_dic = user_dic
+	if _, ok := mecabrc_map["dicdir"]; !ok {
+		mecabrc_map["dicdir"] = "."
 	}
-	cp, err := newCharProperty(get_dic_path(mecabrc_map, "char.bin"))
+	resolve_mecabrc(mecabrc_map, ".")
+	d, err := openDictionary(newDicFiles(mecabrc_map), fsOpener(fsys), fsStater(fsys))
 	if err != nil {
-		return tok, err
+		return nil, err
 	}
+	defer d.Close() // the Tokenizer holds a reference of d
+	return d.newTokenizer(newDefaultOptions(), d.user_dics)
+}
 
-	tok.cp = cp
+func (tok *Tokenizer) validateDic(dic *mecabDic, dic_type int) error {
+	return validateDic(dic, dic_type, tok.m)
+}
 
-	unk_dic, err := newMecabDic(get_dic_path(mecabrc_map, "unk.dic"))
-	if err != nil {
-		return tok, err
+// Resource management. Close() unmaps dictionaries after all running
+// calls are finished, calls after Close() return ErrClosed.
+
+func (tok *Tokenizer) acquire() error {
+	tok.mu.Lock()
+	defer tok.mu.Unlock()
+	if tok.closed {
+		return errTokenizerClosed
 	}
-	tok.unk_dic = unk_dic
-	m, err := newMatrix(get_dic_path(mecabrc_map, "matrix.bin"))
-	if err != nil {
-		return tok, err
+	tok.refs++
+	return nil
+}
+
+func (tok *Tokenizer) release() {
+	tok.mu.Lock()
+	defer tok.mu.Unlock()
+	tok.refs--
+	if tok.closed && tok.refs == 0 {
+		tok.unmap()
+	}
+}
+
+func (tok *Tokenizer) unmap() error {
+	// close user dictionaries and release the shared Dictionary
+	tok.user_mu.RLock()
+	user_dics := tok.user_dics
+	tok.user_mu.RUnlock()
+
+	var err error
+	for _, u := range user_dics {
+		if e := u.dic.close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	tok.dic.release()
+	return err
+}
+
+func (tok *Tokenizer) Close() error {
+	tok.mu.Lock()
+	defer tok.mu.Unlock()
+	if tok.closed {
+		return errTokenizerClosed
+	}
+	tok.closed = true
+	if tok.refs == 0 {
+		return tok.unmap()
+	}
+	return nil
+}
+
+// Config returns the settings in dicrc of the dictionary directory.
+func (tok *Tokenizer) Config() DicConfig {
+	config := *tok.config
+	config.Values = make(map[string]string, len(tok.config.Values))
+	for k, v := range tok.config.Values {
+		config.Values[k] = v
+	}
+	return config
+}
+
+func (tok *Tokenizer) SetWhitespacePolicy(policy WhitespacePolicy) {
+	tok.whitespace = policy
+}
+
+// Map characters out of BMP (U+10000..U+10FFFF) to a character category
+// of char.def. By default CJK Unified Ideographs Extension B and later
+// (U+20000..U+3FFFF) are KANJI, other characters are EMOJI or SYMBOL.
+func (tok *Tokenizer) SetCharCategory(low rune, high rune, category string) error {
+	return tok.cp.setCategory(uint32(low), uint32(high), category)
+}
+
+func (tok *Tokenizer) SetInvalidUTF8Policy(policy InvalidUTF8Policy) {
+	tok.invalid = policy
+}
+
+func replaceInvalidUTF8(str string) ([]byte, []int32) {
+	// returns replaced string and lattice position to byte offset of str
+	s := make([]byte, 0, len(str)+8)
+	byte_pos := make([]int32, 1, len(str)+8)
+	for i := 0; i < len(str); {
+		r, ln := utf8.DecodeRuneInString(str[i:])
+		if r == utf8.RuneError && ln == 1 {
+			s = append(s, "\uFFFD"...)
+		} else {
+			s = append(s, str[i:i+ln]...)
+		}
+		for len(byte_pos) <= len(s) {
+			byte_pos = append(byte_pos, int32(i))
+		}
+		i += ln
 	}
-	tok.m = m
+	byte_pos = append(byte_pos, int32(len(str)), int32(len(str)))
+	return s, byte_pos
+}
 
-	return tok, err
+func (tok *Tokenizer) addEntries(lat *Lattice, c *constraint, pos int, entries []*DicEntry) bool {
+	added := false
+	for _, entry := range entries {
+		if c.allows(pos, len(entry.original), entry.feature) {
+			lat.add(newNode(entry), tok.m)
+			added = true
+		}
+	}
+	return added
 }
 
-func (tok *Tokenizer) buildLattice(str string) (*Lattice, error) {
+func (tok *Tokenizer) buildLattice(str string, constraints *Constraints) (*Lattice, error) {
+	if tok.max_input_size > 0 && len(str) > tok.max_input_size {
+		return nil, fmt.Errorf("%w: input size %d > %d", ErrLatticeLimit, len(str), tok.max_input_size)
+	}
 	s := []byte(str)
-	lat, err := newLattice(len(s))
+	var byte_pos []int32
+	if !utf8.ValidString(str) {
+		switch tok.invalid {
+		case InvalidUTF8Replace:
+			s, byte_pos = replaceInvalidUTF8(str)
+		case InvalidUTF8Bytes:
+		default:
+			return nil, ErrInvalidUTF8
+		}
+	}
+	c, err := newConstraint(constraints, str, s, byte_pos)
+	if err != nil {
+		return nil, err
+	}
+	lat, err := newLattice(s)
+	if err != nil {
+		return nil, err
+	}
+	lat.byte_pos = byte_pos
+	lat.setBosFeature(tok.config.BOSFeature)
+	user_dics := tok.acquireUserDics()
+	defer tok.releaseUserDics(user_dics)
 	pos := 0
 	for pos < len(s) {
 		matched := false
 
-		// user_dic
-		if tok.user_dic != nil {
-			user_entries := tok.user_dic.lookup(s[pos:])
-			if len(user_entries) > 0 {
-				for _, entry := range user_entries {
-					lat.add(newNode(entry), tok.m)
-				}
+		if isInvalidUTF8(s, pos) {
+			tok.addEntries(lat, c, pos, tok.unk_dic.lookupInvalidByte(s[pos:]))
+		} else {
+			// user_dics in priority order
+			if tok.addEntries(lat, c, pos, lookupUserDics(user_dics, s[pos:])) {
+				matched = true
+			}
+
+			// in-memory user dictionary
+			if tok.addEntries(lat, c, pos, alignToGrapheme(tok.mem_dic.lookup(s[pos:]), s[pos:])) {
+				matched = true
+			}
+
+			// sys_dic
+			if tok.addEntries(lat, c, pos, alignToGrapheme(tok.sys_dic.lookup(s[pos:]), s[pos:])) {
 				matched = true
 			}
-		}
 
-		// sys_dic
-		sys_entries := tok.sys_dic.lookup(s[pos:])
-		if len(sys_entries) > 0 {
-			for _, entry := range sys_entries {
-				lat.add(newNode(entry), tok.m)
+			// unknown
+			unk_entries, invoke := tok.unk_dic.lookupUnknowns(s[pos:], tok.cp, tok.max_grouping_size)
+			if invoke || !matched {
+				for _, entry := range unk_entries {
+					if tok.whitespace == WhitespaceToken {
+						entry.skip = false
+					}
+				}
+				tok.addEntries(lat, c, pos, unk_entries)
 			}
-			matched = true
 		}
 
-		// unknown
-		unk_entries, invoke := tok.unk_dic.lookupUnknowns(s[pos:], tok.cp)
-		if invoke || !matched {
-			for _, entry := range unk_entries {
-				lat.add(newNode(entry), tok.m)
+		if c != nil {
+			if err := tok.addConstrainedNodes(lat, c, s, pos); err != nil {
+				return nil, err
 			}
+		}
 
+		if tok.max_nodes > 0 && lat.node_count > tok.max_nodes {
+			return nil, fmt.Errorf("%w: lattice nodes > %d", ErrLatticeLimit, tok.max_nodes)
 		}
 
-		pos += lat.forward()
+		n, err := lat.forward()
+		if err != nil {
+			return nil, err
+		}
+		pos += n
 	}
 
 	lat.end(tok.m)
-	return lat, err
+	return lat, nil
 }
 
-func (tok *Tokenizer) Tokenize(str string) ([][2]string, error) {
-	lat, err := tok.buildLattice(str)
+func tokensToMorphemes(tokens []Token) [][2]string {
+	morphemes := make([][2]string, 0, len(tokens))
+	for _, t := range tokens {
+		morphemes = append(morphemes, [2]string{t.Surface, t.Feature})
+	}
+	return morphemes
+}
+
+// BuildLattice returns the lattice of str to inspect nodes, or to run
+// Viterbi() and NBest() on it. The lattice is available until tok is closed.
+func (tok *Tokenizer) BuildLattice(str string) (lat *Lattice, err error) {
+	if err := tok.acquire(); err != nil {
+		return nil, err
+	}
+	defer tok.release()
+	defer recoverError(&err)
+
+	lat, err = tok.buildLattice(str, nil)
+	if err != nil {
+		return nil, err
+	}
+	lat.tok = tok
+	return lat, nil
+}
+
+func (tok *Tokenizer) TokenizeTokens(str string) (tokens []Token, err error) {
+	if err := tok.acquire(); err != nil {
+		return nil, err
+	}
+	defer tok.release()
+	defer recoverError(&err)
+
+	lat, err := tok.buildLattice(str, nil)
+	if err != nil {
+		return nil, err
+	}
+	return lat.viterbi(tok.m)
+}
+
+func (tok *Tokenizer) TokenizeNBestTokens(str string, n int) (tokens_list [][]Token, err error) {
+	if err := tok.acquire(); err != nil {
+		return nil, err
+	}
+	defer tok.release()
+	defer recoverError(&err)
+
+	lat, err := tok.buildLattice(str, nil)
 	if err != nil {
 		return nil, err
 	}
-	nodes := lat.backward()
+	return lat.nbest(n, tok.m)
+}
 
-	morphemes := make([][2]string, 0)
+// TokenizeAll returns tokens of all the nodes in the lattice of str,
+// see Lattice.All().
+func (tok *Tokenizer) TokenizeAll(str string) (tokens []Token, err error) {
+	if err := tok.acquire(); err != nil {
+		return nil, err
+	}
+	defer tok.release()
+	defer recoverError(&err)
 
-	for i := 1; i < len(nodes)-1; i++ {
-		morphemes = append(morphemes, [2]string{nodes[i].original, nodes[i].feature})
+	lat, err := tok.buildLattice(str, nil)
+	if err != nil {
+		return nil, err
 	}
+	return lat.all()
+}
 
-	return morphemes, nil
+func (tok *Tokenizer) Tokenize(str string) ([][2]string, error) {
+	tokens, err := tok.TokenizeTokens(str)
+	if err != nil {
+		return nil, err
+	}
+	return tokensToMorphemes(tokens), nil
 }
 
 func (tok *Tokenizer) TokenizeNBest(str string, n int) ([][][2]string, error) {
-	lat, err := tok.buildLattice(str)
+	tokens_list, err := tok.TokenizeNBestTokens(str, n)
 	if err != nil {
 		return nil, err
 	}
 
-	nodes_list := lat.backwardAstar(n, tok.m)
-	morphemes_list := make([][][2]string, 0)
-	for _, nodes := range nodes_list {
-		morphemes := make([][2]string, 0)
-		for i := 1; i < len(nodes)-1; i++ {
-			morphemes = append(morphemes, [2]string{nodes[i].original, nodes[i].feature})
-		}
-		morphemes_list = append(morphemes_list, morphemes)
+	morphemes_list := make([][][2]string, 0, len(tokens_list))
+	for _, tokens := range tokens_list {
+		morphemes_list = append(morphemes_list, tokensToMorphemes(tokens))
 	}
 
 	return morphemes_list, nil
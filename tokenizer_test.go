@@ -25,7 +25,12 @@ SOFTWARE.
 package goawabi
 
 import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
 	"testing"
+	"testing/fstest"
 )
 
 func TestTokenizer(t *testing.T) {
@@ -97,3 +102,135 @@ func TestTokenizer(t *testing.T) {
 	}
 
 }
+
+func TestClose(t *testing.T) {
+	tokenizer := newTestTokenizer(t)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				morphemes, err := tokenizer.Tokenize("すもももももももものうち")
+				if errors.Is(err, ErrClosed) {
+					return
+				}
+				if err != nil || len(morphemes) != 7 {
+					t.Errorf("Tokenize() failed:%v %v", morphemes, err)
+				}
+			}
+		}()
+	}
+	if err := tokenizer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+
+	if _, err := tokenizer.Tokenize("すもも"); !errors.Is(err, ErrClosed) {
+		t.Errorf("Tokenize() after Close() must return ErrClosed:%v", err)
+	}
+	if _, err := tokenizer.TokenizeNBest("すもも", 2); !errors.Is(err, ErrClosed) {
+		t.Errorf("TokenizeNBest() after Close() must return ErrClosed:%v", err)
+	}
+	if err := tokenizer.Close(); !errors.Is(err, ErrClosed) || err.Error() != "Tokenizer is closed" {
+		t.Errorf("Close() twice must return ErrClosed:%v", err)
+	}
+}
+
+func TestNewTokenizerFS(t *testing.T) {
+	dir := compileTestDictionary(t)
+	err := CompileUserDictionary(dir, []string{filepath.Join("testdata", "userdic", "user.csv")}, filepath.Join(dir, "user.dic"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fsys := fstest.MapFS{}
+	for _, name := range []string{"sys.dic", "unk.dic", "matrix.bin", "char.bin", "user.dic"} {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		fsys["ipadic/"+name] = &fstest.MapFile{Data: data}
+	}
+
+	tokenizer, err := NewTokenizerFS(fsys, "dicdir = ipadic\nuserdic = ipadic/user.dic\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tokenizer.sys_dic.mapped {
+		t.Errorf("fstest.MapFS must be read into memory")
+	}
+	morphemes, err := tokenizer.Tokenize("母はハハハと笑う")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(morphemes) != 5 || morphemes[2][1] != "感動詞,*,*,*,*,*,ハハハ,ハハハ,ハハハ" {
+		t.Errorf("Tokenize() failed:%v", morphemes)
+	}
+	if err := tokenizer.Close(); err != nil {
+		t.Error(err)
+	}
+
+	tokenizer, err = NewTokenizerFS(os.DirFS(dir), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tokenizer.sys_dic.mapped != mmapSupported {
+		t.Errorf("os.DirFS must be mmapped if supported")
+	}
+	morphemes, err = tokenizer.Tokenize("すもももももももものうち")
+	if err != nil || len(morphemes) != 7 {
+		t.Errorf("Tokenize() failed:%v %v", morphemes, err)
+	}
+	tokenizer.Close()
+
+	if _, err := NewTokenizerFS(fsys, "dicdir = unknown\n"); err == nil {
+		t.Errorf("NewTokenizerFS() must fail without dictionary")
+	}
+}
+
+func TestInvalidUTF8Policy(t *testing.T) {
+	tokenizer := newTestTokenizer(t)
+	s := "すもも\xffの\xe3\x81うち"
+
+	if _, err := tokenizer.Tokenize(s); !errors.Is(err, ErrInvalidUTF8) {
+		t.Errorf("Tokenize() must return ErrInvalidUTF8:%v", err)
+	}
+
+	tokenizer.SetInvalidUTF8Policy(InvalidUTF8Replace)
+	tokens, err := tokenizer.TokenizeTokens(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	surfaces := make([]string, 0)
+	for _, token := range tokens {
+		surfaces = append(surfaces, token.Surface)
+	}
+	if len(tokens) != 5 || tokens[1].Surface != "�" || tokens[3].Surface != "��" {
+		t.Fatalf("TokenizeTokens() with InvalidUTF8Replace failed:%v", surfaces)
+	}
+	if tokens[1].Start != 9 || tokens[1].End != 10 || tokens[2].Start != 10 || tokens[3].End != 15 || tokens[4].Start != 15 {
+		t.Errorf("TokenizeTokens() with InvalidUTF8Replace offsets failed:%v", tokens)
+	}
+	if tokens[4].RuneStart != 7 || tokens[4].RuneEnd != 9 {
+		t.Errorf("TokenizeTokens() with InvalidUTF8Replace rune offsets failed:%v", tokens[4])
+	}
+
+	tokenizer.SetInvalidUTF8Policy(InvalidUTF8Bytes)
+	tokens, err = tokenizer.TokenizeTokens(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tokens) != 6 || tokens[1].Surface != "\xff" || tokens[3].Surface != "\xe3" || tokens[4].Surface != "\x81" {
+		t.Fatalf("TokenizeTokens() with InvalidUTF8Bytes failed:%v", tokens)
+	}
+	for _, token := range tokens {
+		if s[token.Start:token.End] != token.Surface {
+			t.Errorf("TokenizeTokens() with InvalidUTF8Bytes offsets failed:%v", token)
+		}
+	}
+	if tokens[1].Status != UnknownToken || tokens[5].RuneStart != 7 {
+		t.Errorf("TokenizeTokens() with InvalidUTF8Bytes failed:%v", tokens)
+	}
+}
@@ -0,0 +1,312 @@
+/*****************************************************************************
+MIT License
+
+Copyright (c) 2020 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*****************************************************************************/
+
+package goawabi
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// User dictionaries
+
+// Token.Dictionary of tokens which are not from user dictionaries
+const (
+	SystemDictionary  = "sys"
+	UnknownDictionary = "unk"
+	MemoryDictionary  = "memory"
+)
+
+// A user dictionary is replaced by ReloadUserDictionary(), and the old one
+// is unmapped after running calls using it are finished.
+// Fields except dic, path, open, stat are protected by Tokenizer.user_mu.
+type userDic struct {
+	dic     *mecabDic
+	path    string
+	open    fileOpener
+	stat    fileStater
+	mtime   time.Time // zero if unknown
+	size    int64
+	enabled bool
+	refs    int
+	retired bool
+}
+
+// modification time and size of a file for WatchUserDictionaries()
+type fileStater func(path string) (time.Time, int64)
+
+func statFile(path string) (time.Time, int64) {
+	if fi, err := os.Stat(path); err == nil {
+		return fi.ModTime(), fi.Size()
+	}
+	return time.Time{}, 0
+}
+
+func fsStater(fsys fs.FS) fileStater {
+	return func(path string) (time.Time, int64) {
+		if fi, err := fs.Stat(fsys, filepath.ToSlash(path)); err == nil {
+			return fi.ModTime(), fi.Size()
+		}
+		return time.Time{}, 0
+	}
+}
+
+func (tok *Tokenizer) loadUserDic(name string, path string, open fileOpener, stat fileStater) (*userDic, error) {
+	mtime, size := stat(path)
+	dic, err := newMecabDicWithOpener(path, open)
+	if err != nil {
+		return nil, err
+	}
+	if err := tok.validateDic(dic, USR_DIC); err != nil {
+		dic.close()
+		return nil, err
+	}
+	dic.name = name
+	return &userDic{dic: dic, path: path, open: open, stat: stat, mtime: mtime, size: size, enabled: true}, nil
+}
+
+func (tok *Tokenizer) acquireUserDics() []*userDic {
+	tok.user_mu.Lock()
+	defer tok.user_mu.Unlock()
+	dics := make([]*userDic, 0, len(tok.user_dics))
+	for _, u := range tok.user_dics {
+		if u.enabled {
+			u.refs++
+			dics = append(dics, u)
+		}
+	}
+	return dics
+}
+
+func (tok *Tokenizer) releaseUserDics(dics []*userDic) {
+	tok.user_mu.Lock()
+	defer tok.user_mu.Unlock()
+	for _, u := range dics {
+		u.refs--
+		if u.retired && u.refs == 0 {
+			u.dic.close()
+		}
+	}
+}
+
+func lookupUserDics(dics []*userDic, s []byte) []*DicEntry {
+	// a dictionary shadows the same surfaces of lower priority dictionaries
+	results := make([]*DicEntry, 0)
+	var shadowed map[int]bool
+	for i, u := range dics {
+		entries := alignToGrapheme(u.dic.lookup(s), s)
+		for _, entry := range entries {
+			if shadowed[len(entry.original)] {
+				continue
+			}
+			results = append(results, entry)
+		}
+		if len(entries) > 0 && i < len(dics)-1 {
+			if shadowed == nil {
+				shadowed = make(map[int]bool)
+			}
+			for _, entry := range entries {
+				shadowed[len(entry.original)] = true
+			}
+		}
+	}
+	return results
+}
+
+func (tok *Tokenizer) findUserDic(name string) (int, *userDic) {
+	for i, u := range tok.user_dics {
+		if u.dic.name == name {
+			return i, u
+		}
+	}
+	return -1, nil
+}
+
+// AddUserDictionary loads a compiled user dictionary at path with the lowest priority.
+// Dictionaries of userdic in mecabrc are named with their path, and
+// name is used for Token.Dictionary and EnableUserDictionary().
+// If name is empty, path is used.
+func (tok *Tokenizer) AddUserDictionary(name string, path string) error {
+	if err := tok.acquire(); err != nil {
+		return err
+	}
+	defer tok.release()
+	if name == "" {
+		name = path
+	}
+	tok.user_mu.RLock()
+	_, exists := tok.findUserDic(name)
+	tok.user_mu.RUnlock()
+	if exists != nil {
+		return errors.New("User dictionary already exists: " + name)
+	}
+
+	u, err := tok.loadUserDic(name, path, openFile, statFile)
+	if err != nil {
+		return err
+	}
+
+	tok.user_mu.Lock()
+	defer tok.user_mu.Unlock()
+	if _, exists := tok.findUserDic(name); exists != nil {
+		u.dic.close()
+		return errors.New("User dictionary already exists: " + name)
+	}
+	tok.user_dics = append(tok.user_dics, u)
+	return nil
+}
+
+// EnableUserDictionary enables or disables the user dictionary of name.
+// Tokenizing in progress is not affected.
+func (tok *Tokenizer) EnableUserDictionary(name string, enabled bool) error {
+	tok.user_mu.Lock()
+	defer tok.user_mu.Unlock()
+	_, u := tok.findUserDic(name)
+	if u == nil {
+		return errors.New("Can't find user dictionary: " + name)
+	}
+	u.enabled = enabled
+	return nil
+}
+
+// UserDictionaries returns names of the user dictionaries in priority order.
+func (tok *Tokenizer) UserDictionaries() []string {
+	tok.user_mu.RLock()
+	defer tok.user_mu.RUnlock()
+	names := make([]string, 0, len(tok.user_dics))
+	for _, u := range tok.user_dics {
+		names = append(names, u.dic.name)
+	}
+	return names
+}
+
+// ReloadUserDictionary loads the user dictionary of name from its path again,
+// validates it and replaces the old one. Running calls finish with the old one,
+// and it is unmapped after them. On error the old one is kept.
+// Replace the file by rename, because overwriting a mmapped file breaks the old one.
+func (tok *Tokenizer) ReloadUserDictionary(name string) error {
+	if err := tok.acquire(); err != nil {
+		return err
+	}
+	defer tok.release()
+	tok.user_mu.RLock()
+	_, old := tok.findUserDic(name)
+	tok.user_mu.RUnlock()
+	if old == nil {
+		return errors.New("Can't find user dictionary: " + name)
+	}
+
+	u, err := tok.loadUserDic(name, old.path, old.open, old.stat)
+	if err != nil {
+		return err
+	}
+
+	tok.user_mu.Lock()
+	defer tok.user_mu.Unlock()
+	i, current := tok.findUserDic(name)
+	if current == nil {
+		u.dic.close()
+		return errors.New("Can't find user dictionary: " + name)
+	}
+	u.enabled = current.enabled
+	user_dics := make([]*userDic, len(tok.user_dics))
+	copy(user_dics, tok.user_dics)
+	user_dics[i] = u
+	tok.user_dics = user_dics
+	current.retired = true
+	if current.refs == 0 {
+		current.dic.close()
+	}
+	return nil
+}
+
+// WatchUserDictionaries polls modification time and size of the user dictionary
+// files every interval, and reloads changed ones. Errors of reloading are passed
+// to on_error if it is not nil. Call stop() to finish watching, it also
+// finishes when the Tokenizer is closed.
+func (tok *Tokenizer) WatchUserDictionaries(interval time.Duration, on_error func(name string, err error)) (stop func()) {
+	done := make(chan struct{})
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if !tok.reloadChangedUserDics(on_error) {
+					return
+				}
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+	}
+}
+
+func (tok *Tokenizer) reloadChangedUserDics(on_error func(name string, err error)) bool {
+	// returns false if the Tokenizer is closed
+	if err := tok.acquire(); err != nil {
+		return false
+	}
+	defer tok.release()
+
+	type changed struct {
+		name  string
+		u     *userDic
+		mtime time.Time
+		size  int64
+	}
+	changes := make([]changed, 0)
+	tok.user_mu.RLock()
+	for _, u := range tok.user_dics {
+		if u.mtime.IsZero() {
+			continue
+		}
+		mtime, size := u.stat(u.path)
+		if !mtime.IsZero() && (!mtime.Equal(u.mtime) || size != u.size) {
+			changes = append(changes, changed{u.dic.name, u, mtime, size})
+		}
+	}
+	tok.user_mu.RUnlock()
+
+	for _, c := range changes {
+		if err := tok.ReloadUserDictionary(c.name); err != nil {
+			// don't retry until the file is changed again
+			tok.user_mu.Lock()
+			c.u.mtime, c.u.size = c.mtime, c.size
+			tok.user_mu.Unlock()
+			if on_error != nil {
+				on_error(c.name, err)
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,204 @@
+/*****************************************************************************
+MIT License
+
+Copyright (c) 2020 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*****************************************************************************/
+
+package goawabi
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestUserDictionaries(t *testing.T) {
+	dir := compileTestDictionary(t)
+	csv_path := filepath.Join(dir, "slang.csv")
+	if err := os.WriteFile(csv_path, []byte("山嵐,1,1,1000,名詞,一般,*,*,*,*,山嵐,ヤマアラシ,ヤマアラシ\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	person_path := filepath.Join(dir, "person.dic")
+	if err := CompileUserDictionary(dir, []string{filepath.Join("testdata", "userdic", "user.csv")}, person_path); err != nil {
+		t.Fatal(err)
+	}
+	slang_path := filepath.Join(dir, "slang.dic")
+	if err := CompileUserDictionary(dir, []string{csv_path}, slang_path); err != nil {
+		t.Fatal(err)
+	}
+
+	tokenizer, err := NewTokenizer(writeTestMecabrc(t, dir, "userdic = "+person_path+"\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tokenizer.Close()
+	if err := tokenizer.AddUserDictionary("slang", slang_path); err != nil {
+		t.Fatal(err)
+	}
+	if tokenizer.AddUserDictionary("slang", slang_path) == nil {
+		t.Errorf("AddUserDictionary() must fail for the same name")
+	}
+	if tokenizer.AddUserDictionary("sys", filepath.Join(dir, "sys.dic")) == nil {
+		t.Errorf("AddUserDictionary() must fail for sys.dic")
+	}
+	if names := tokenizer.UserDictionaries(); len(names) != 2 || names[0] != person_path || names[1] != "slang" {
+		t.Errorf("UserDictionaries() failed:%v", names)
+	}
+
+	assertDictionary := func(dic string, feature string) {
+		t.Helper()
+		tokens, err := tokenizer.TokenizeTokens("山嵐は母")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(tokens) != 3 || tokens[0].Dictionary != dic || tokens[0].Features[1] != feature || tokens[1].Dictionary != SystemDictionary {
+			t.Errorf("TokenizeTokens() failed:%v", tokens)
+		}
+	}
+	// person.dic shadows slang.dic
+	assertDictionary(person_path, "固有名詞")
+	if err := tokenizer.EnableUserDictionary(person_path, false); err != nil {
+		t.Fatal(err)
+	}
+	assertDictionary("slang", "一般")
+	if err := tokenizer.EnableUserDictionary("slang", false); err != nil {
+		t.Fatal(err)
+	}
+	if tokenizer.EnableUserDictionary("unknown", false) == nil {
+		t.Errorf("EnableUserDictionary() must fail for unknown name")
+	}
+	tokens, err := tokenizer.TokenizeTokens("山嵐")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, token := range tokens {
+		if token.Dictionary != SystemDictionary && token.Dictionary != UnknownDictionary {
+			t.Errorf("TokenizeTokens() failed:%v", tokens)
+		}
+	}
+
+	if err := tokenizer.AddWord("山嵐", 1, 1, 0, "名詞,一般,*,*,*,*,山嵐,ヤマアラシ,ヤマアラシ"); err != nil {
+		t.Fatal(err)
+	}
+	tokens, err = tokenizer.TokenizeTokens("山嵐")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tokens) != 1 || tokens[0].Dictionary != MemoryDictionary {
+		t.Errorf("TokenizeTokens() failed:%v", tokens)
+	}
+}
+
+func compileTestUserDic(t *testing.T, dir string, path string, csv string) {
+	t.Helper()
+	csv_path := filepath.Join(t.TempDir(), "user.csv")
+	if err := os.WriteFile(csv_path, []byte(csv), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tmp := path + ".tmp"
+	if err := CompileUserDictionary(dir, []string{csv_path}, tmp); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReloadUserDictionary(t *testing.T) {
+	dir := compileTestDictionary(t)
+	path := filepath.Join(t.TempDir(), "user.dic")
+	compileTestUserDic(t, dir, path, "ハハハ,1,1,1000,感動詞,*,*,*,*,*,ハハハ,ハハハ,ハハハ\n")
+	tokenizer, err := NewTokenizerWithOptions(WithDicDir(dir), WithUserDic(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tokenizer.Close()
+	assertFeature := func(expected string) {
+		t.Helper()
+		tokens, err := tokenizer.TokenizeTokens("ハハハ")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(tokens) != 1 || tokens[0].Features[0] != expected {
+			t.Errorf("TokenizeTokens() failed:%v", tokens)
+		}
+	}
+	assertFeature("感動詞")
+
+	// a running call keeps the old dictionary
+	running := tokenizer.acquireUserDics()
+	compileTestUserDic(t, dir, path, "ハハハ,1,1,1000,名詞,一般,*,*,*,*,ハハハ,ハハハ,ハハハ\n")
+	if err := tokenizer.ReloadUserDictionary(path); err != nil {
+		t.Fatal(err)
+	}
+	assertFeature("名詞")
+	if running[0].dic.data == nil {
+		t.Errorf("old dictionary must not be unmapped while it is used")
+	}
+	tokenizer.releaseUserDics(running)
+	if running[0].dic.data != nil {
+		t.Errorf("old dictionary must be unmapped")
+	}
+
+	// invalid dictionary is not loaded
+	if err := os.WriteFile(path+".tmp", []byte("broken"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	os.Rename(path+".tmp", path)
+	if tokenizer.ReloadUserDictionary(path) == nil {
+		t.Errorf("ReloadUserDictionary() must fail for broken file")
+	}
+	assertFeature("名詞")
+	if tokenizer.ReloadUserDictionary("unknown") == nil {
+		t.Errorf("ReloadUserDictionary() must fail for unknown name")
+	}
+
+	// watcher
+	compileTestUserDic(t, dir, path, "ハハハ,1,1,1000,副詞,一般,*,*,*,*,ハハハ,ハハハ,ハハハ\n")
+	future := time.Now().Add(time.Hour)
+	if err := os.Chtimes(path, future, future); err != nil {
+		t.Fatal(err)
+	}
+	errs := make(chan error, 10)
+	stop := tokenizer.WatchUserDictionaries(10*time.Millisecond, func(name string, err error) {
+		errs <- err
+	})
+	defer stop()
+	for i := 0; ; i++ {
+		tokens, err := tokenizer.TokenizeTokens("ハハハ")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tokens[0].Features[0] == "副詞" {
+			break
+		}
+		if i > 200 {
+			t.Fatalf("user dictionary is not reloaded:%v", tokens)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	select {
+	case err := <-errs:
+		t.Errorf("unexpected reload error:%v", err)
+	default:
+	}
+}
@@ -0,0 +1,260 @@
+/*****************************************************************************
+MIT License
+
+Copyright (c) 2020 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*****************************************************************************/
+
+package goawabi
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Lattice visualization
+
+type latticeEdge struct {
+	left  *Node
+	right *Node
+	cost  int32 // connection cost
+	best  bool
+}
+
+func (lat *Lattice) visibleNodes() []*Node {
+	// nodes except skipped whitespaces, edges pass through them
+	nodes := make([]*Node, 0, lat.node_count+1)
+	for _, node := range lat.Nodes() {
+		if !node.skip {
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes
+}
+
+func (lat *Lattice) bestNodes() map[*Node]bool {
+	best := make(map[*Node]bool)
+	for node := lat.EOS(); node != nil; node = node.BestPrev() {
+		best[node] = true
+	}
+	return best
+}
+
+func (lat *Lattice) edges(node *Node, best map[*Node]bool, m *matrix) []latticeEdge {
+	// edges to node
+	if node.isBos() {
+		return nil
+	}
+	edges := make([]latticeEdge, 0)
+	for _, left := range lat.leftNodes(node.pos) {
+		cost := m.getTransCost(int(left.right_id), int(node.left_id))
+		edges = append(edges, latticeEdge{left, node, cost, best[node] && node.BestPrev() == left})
+	}
+	return edges
+}
+
+func nodeName(node *Node) string {
+	switch {
+	case node.isBos():
+		return "BOS"
+	case node.isEos():
+		return "EOS"
+	}
+	return node.original
+}
+
+func nodePos(node *Node) string {
+	if node.isBos() || node.isEos() {
+		return ""
+	}
+	return splitFeature(node.feature)[0]
+}
+
+func dotEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+// WriteDot writes the lattice in Graphviz DOT language. Nodes are labelled with
+// surface, part of speech, word cost and the cost of the best path to them,
+// edges with connection costs. The best path is drawn in bold red.
+func (lat *Lattice) WriteDot(w io.Writer) (err error) {
+	if err := lat.tok.acquire(); err != nil {
+		return err
+	}
+	defer lat.tok.release()
+	defer recoverError(&err)
+
+	m := lat.tok.m
+	nodes := lat.visibleNodes()
+	best := lat.bestNodes()
+	ids := make(map[*Node]int, len(nodes))
+	for i, node := range nodes {
+		ids[node] = i
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "digraph lattice {\n")
+	fmt.Fprintf(bw, "  rankdir=LR;\n")
+	fmt.Fprintf(bw, "  node [shape=box];\n")
+	for _, node := range nodes {
+		label := fmt.Sprintf("%s\n%s\n%d / %d", nodeName(node), nodePos(node), node.cost, node.min_cost)
+		attrs := ""
+		if best[node] {
+			attrs = ", color=red, penwidth=2"
+		}
+		fmt.Fprintf(bw, "  n%d [label=\"%s\"%s];\n", ids[node], dotEscape(label), attrs)
+	}
+	for _, node := range nodes {
+		for _, e := range lat.edges(node, best, m) {
+			attrs := ""
+			if e.best {
+				attrs = ", color=red, penwidth=2"
+			}
+			fmt.Fprintf(bw, "  n%d -> n%d [label=\"%d\"%s];\n", ids[e.left], ids[e.right], e.cost, attrs)
+		}
+	}
+	fmt.Fprintf(bw, "}\n")
+	return bw.Flush()
+}
+
+const latticeHTMLHeader = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>lattice</title>
+<style>
+body { font-family: sans-serif; }
+.lattice { display: grid; gap: 4px; margin: 1em 0; }
+.node { border: 1px solid #888; border-radius: 4px; padding: 2px 6px; text-align: center; white-space: nowrap; }
+.node small { color: #666; }
+.best { border: 2px solid #d00; background: #fee; }
+table { border-collapse: collapse; }
+td, th { border: 1px solid #ccc; padding: 2px 8px; }
+td.cost { text-align: right; }
+</style>
+</head>
+<body>
+`
+
+const latticeHTMLFooter = `</body>
+</html>
+`
+
+// WriteHTML writes the lattice as a self-contained HTML page, see WriteLatticesHTML().
+func (lat *Lattice) WriteHTML(w io.Writer) error {
+	return WriteLatticesHTML(w, []*Lattice{lat})
+}
+
+// WriteLatticesHTML writes lattices as a self-contained HTML page.
+// Nodes are boxes spanning their characters, the best path is highlighted,
+// and connection costs of edges to a node are shown as its tooltip.
+// A table of the best path shows how its cost is summed up.
+func WriteLatticesHTML(w io.Writer, lats []*Lattice) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprint(bw, latticeHTMLHeader)
+	for _, lat := range lats {
+		if err := lat.writeHTML(bw); err != nil {
+			return err
+		}
+	}
+	fmt.Fprint(bw, latticeHTMLFooter)
+	return bw.Flush()
+}
+
+func (lat *Lattice) writeHTML(w io.Writer) (err error) {
+	if err := lat.tok.acquire(); err != nil {
+		return err
+	}
+	defer lat.tok.release()
+	defer recoverError(&err)
+
+	m := lat.tok.m
+	nodes := lat.visibleNodes()
+	best := lat.bestNodes()
+
+	// grid columns are boundaries of nodes
+	columns := make(map[int32]int)
+	for _, node := range nodes {
+		columns[node.pos] = 0
+		columns[node.epos] = 0
+	}
+	positions := make([]int32, 0, len(columns))
+	for pos := range columns {
+		positions = append(positions, pos)
+	}
+	sort.Slice(positions, func(i, j int) bool { return positions[i] < positions[j] })
+	for i, pos := range positions {
+		columns[pos] = i + 1
+	}
+
+	path := make([]*Node, 0)
+	for node := lat.EOS(); node != nil; node = node.BestPrev() {
+		path = append(path, node)
+	}
+	reverseNodes(path)
+	surfaces := make([]string, 0, len(path))
+	for _, node := range path[1 : len(path)-1] {
+		surfaces = append(surfaces, node.original)
+	}
+
+	fmt.Fprintf(w, "<section>\n<h2>%s</h2>\n", html.EscapeString(strings.Join(surfaces, " | ")))
+	fmt.Fprintf(w, "<div class=\"lattice\" style=\"grid-template-columns: repeat(%d, auto)\">\n", len(positions)-1)
+	row_ends := make([]int32, 0) // end position of the last node in each row
+	for _, node := range nodes {
+		row := 0
+		for row < len(row_ends) && row_ends[row] > node.pos {
+			row++
+		}
+		if row == len(row_ends) {
+			row_ends = append(row_ends, 0)
+		}
+		row_ends[row] = node.epos
+
+		tooltip := make([]string, 0)
+		if !node.isBos() && !node.isEos() {
+			tooltip = append(tooltip, node.feature)
+		}
+		for _, e := range lat.edges(node, best, m) {
+			tooltip = append(tooltip, fmt.Sprintf("%s -> %s: %d", nodeName(e.left), nodeName(node), e.cost))
+		}
+		class := "node"
+		if best[node] {
+			class += " best"
+		}
+		fmt.Fprintf(w, "<div class=\"%s\" style=\"grid-column: %d / %d; grid-row: %d\" title=\"%s\">%s<br><small>%s</small><br><small>%d / %d</small></div>\n",
+			class, columns[node.pos], columns[node.epos], row+1, html.EscapeString(strings.Join(tooltip, "\n")),
+			html.EscapeString(nodeName(node)), html.EscapeString(nodePos(node)), node.cost, node.min_cost)
+	}
+	fmt.Fprintf(w, "</div>\n")
+
+	fmt.Fprintf(w, "<table>\n<tr><th>surface</th><th>feature</th><th>connection cost</th><th>word cost</th><th>cost</th></tr>\n")
+	for i := 1; i < len(path); i++ {
+		node := path[i]
+		cost := m.getTransCost(int(path[i-1].right_id), int(node.left_id))
+		fmt.Fprintf(w, "<tr><td>%s</td><td>%s</td><td class=\"cost\">%d</td><td class=\"cost\">%d</td><td class=\"cost\">%d</td></tr>\n",
+			html.EscapeString(nodeName(node)), html.EscapeString(node.feature), cost, node.cost, node.min_cost)
+	}
+	fmt.Fprintf(w, "</table>\n</section>\n")
+	return nil
+}
@@ -0,0 +1,91 @@
+/*****************************************************************************
+MIT License
+
+Copyright (c) 2020 Hajime Nakagami
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*****************************************************************************/
+
+package goawabi
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestWriteDot(t *testing.T) {
+	tokenizer := newTestTokenizer(t)
+	s := "すもももももももものうち"
+	lat, err := tokenizer.BuildLattice(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens, _ := tokenizer.TokenizeTokens(s)
+
+	var buf bytes.Buffer
+	if err := lat.WriteDot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	dot := buf.String()
+	if !strings.HasPrefix(dot, "digraph lattice {\n") || !strings.HasSuffix(dot, "}\n") {
+		t.Errorf("WriteDot() failed:%s", dot)
+	}
+	if n := strings.Count(dot, "[label="); n <= len(lat.Nodes()) {
+		t.Errorf("WriteDot() must write nodes and edges:%d", n)
+	}
+	// best nodes and edges
+	if n := strings.Count(dot, "color=red"); n != (len(tokens)+2)+(len(tokens)+1) {
+		t.Errorf("WriteDot() best path failed:%d", n)
+	}
+	if !strings.Contains(dot, `[label="すもも\n名詞\n3000 / 2800", color=red, penwidth=2]`) {
+		t.Errorf("WriteDot() node label failed:%s", dot)
+	}
+
+	buf.Reset()
+	if err := lat.WriteHTML(&buf); err != nil {
+		t.Fatal(err)
+	}
+	page := buf.String()
+	if !strings.HasPrefix(page, "<!DOCTYPE html>") || !strings.HasSuffix(page, "</html>\n") {
+		t.Errorf("WriteHTML() failed:%s", page)
+	}
+	if n := strings.Count(page, `class="node best"`); n != len(tokens)+2 {
+		t.Errorf("WriteHTML() best path failed:%d", n)
+	}
+	if !strings.Contains(page, "<h2>すもも | も | もも | も | もも | の | うち</h2>") {
+		t.Errorf("WriteHTML() failed:%s", page)
+	}
+
+	// skipped whitespaces are not drawn
+	lat2, _ := tokenizer.BuildLattice("すもも もも")
+	buf.Reset()
+	if err := WriteLatticesHTML(&buf, []*Lattice{lat, lat2}); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(buf.String(), "<section>") != 2 || strings.Contains(buf.String(), "記号,空白") {
+		t.Errorf("WriteLatticesHTML() failed:%s", buf.String())
+	}
+
+	tokenizer.Close()
+	if err := lat.WriteDot(&buf); !errors.Is(err, ErrClosed) {
+		t.Errorf("WriteDot() after Close() must be ErrClosed:%v", err)
+	}
+}